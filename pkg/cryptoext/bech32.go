@@ -0,0 +1,145 @@
+package cryptoext
+
+import (
+	"errors"
+	"strings"
+)
+
+// bech32 encoding (BIP 173) as used by age keys. Unlike BIP 173 the
+// 90 character length limit is not enforced.
+
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+var errInvalidBech32 = errors.New("invalid bech32 string")
+
+var bech32Generator = [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+
+func bech32Polymod(values []byte) uint32 {
+	chk := uint32(1)
+
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+
+		for i := 0; i < 5; i++ {
+			if (top>>uint(i))&1 == 1 {
+				chk ^= bech32Generator[i]
+			}
+		}
+	}
+
+	return chk
+}
+
+func bech32HRPExpand(hrp string) []byte {
+	ans := make([]byte, 0, len(hrp)*2+1)
+
+	for i := 0; i < len(hrp); i++ {
+		ans = append(ans, hrp[i]>>5)
+	}
+
+	ans = append(ans, 0)
+
+	for i := 0; i < len(hrp); i++ {
+		ans = append(ans, hrp[i]&31)
+	}
+
+	return ans
+}
+
+func bech32ConvertBits(data []byte, from, to uint, pad bool) ([]byte, error) {
+	var (
+		acc  uint32
+		bits uint
+		ans  []byte
+	)
+
+	maxv := uint32(1)<<to - 1
+
+	for _, b := range data {
+		if uint32(b)>>from != 0 {
+			return nil, errInvalidBech32
+		}
+
+		acc = acc<<from | uint32(b)
+		bits += from
+
+		for bits >= to {
+			bits -= to
+			ans = append(ans, byte(acc>>bits&maxv))
+		}
+	}
+
+	if pad {
+		if bits > 0 {
+			ans = append(ans, byte(acc<<(to-bits)&maxv))
+		}
+	} else if bits >= from || acc<<(to-bits)&maxv != 0 {
+		return nil, errInvalidBech32
+	}
+
+	return ans, nil
+}
+
+func bech32Encode(hrp string, data []byte) (string, error) {
+	values, err := bech32ConvertBits(data, 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+
+	hrp = strings.ToLower(hrp)
+
+	polymod := bech32Polymod(append(append(bech32HRPExpand(hrp), values...), 0, 0, 0, 0, 0, 0)) ^ 1
+
+	var sb strings.Builder
+
+	sb.WriteString(hrp)
+	sb.WriteByte('1')
+
+	for _, v := range values {
+		sb.WriteByte(bech32Charset[v])
+	}
+
+	for i := 0; i < 6; i++ {
+		sb.WriteByte(bech32Charset[(polymod>>uint(5*(5-i)))&31])
+	}
+
+	return sb.String(), nil
+}
+
+func bech32Decode(s string) (hrp string, data []byte, err error) {
+	if strings.ToLower(s) != s && strings.ToUpper(s) != s {
+		return "", nil, errInvalidBech32
+	}
+
+	s = strings.ToLower(s)
+
+	pos := strings.LastIndexByte(s, '1')
+	if pos < 1 || pos+7 > len(s) {
+		return "", nil, errInvalidBech32
+	}
+
+	hrp = s[:pos]
+
+	values := make([]byte, 0, len(s)-pos-1)
+
+	for i := pos + 1; i < len(s); i++ {
+		idx := strings.IndexByte(bech32Charset, s[i])
+		if idx < 0 {
+			return "", nil, errInvalidBech32
+		}
+
+		values = append(values, byte(idx))
+	}
+
+	if bech32Polymod(append(bech32HRPExpand(hrp), values...)) != 1 {
+		return "", nil, errInvalidBech32
+	}
+
+	data, err = bech32ConvertBits(values[:len(values)-6], 5, 8, false)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return hrp, data, nil
+}
@@ -0,0 +1,95 @@
+package cryptoext
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sort"
+
+	"github.com/gosom/toolkit/pkg/errorsext"
+)
+
+const blindIndexKeyInfo = "cryptoext/blind-index/v1/"
+
+var ErrInvalidBlindIndexSize = errors.New("blind index size must be between 1 and 32 bytes")
+
+// BlindIndex returns a keyed hash of value that can be stored next to an
+// encrypted column and used for equality lookups without decrypting it.
+//
+// name separates the indexes of different columns, so equal values in two
+// columns do not produce the same index. size truncates the index to the
+// given number of bytes: shorter indexes leak less about the value because
+// unrelated values collide, at the cost of false positives that must be
+// filtered after decryption.
+//
+// Values should be normalized (e.g. lowercased emails) before indexing.
+func (k *Keyring) BlindIndex(name string, value []byte, size int) (string, error) {
+	if size <= 0 || size > sha256.Size {
+		return "", errorsext.WithStack(ErrInvalidBlindIndexSize)
+	}
+
+	_, key, err := k.derivedPrimaryKey(blindIndexKeyInfo + name)
+	if err != nil {
+		return "", err
+	}
+
+	return blindIndex(key, value, size), nil
+}
+
+// BlindIndexes returns the blind index of value for every key in the keyring,
+// starting with the primary one. It is meant for lookups while rows indexed
+// with older keys are being migrated.
+func (k *Keyring) BlindIndexes(name string, value []byte, size int) ([]string, error) {
+	if size <= 0 || size > sha256.Size {
+		return nil, errorsext.WithStack(ErrInvalidBlindIndexSize)
+	}
+
+	primary, err := k.Primary()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := k.keyIDs()
+
+	sort.SliceStable(ids, func(i, j int) bool {
+		return ids[i] == primary && ids[j] != primary
+	})
+
+	ans := make([]string, 0, len(ids))
+
+	for _, id := range ids {
+		key, err := k.derivedKey(id, blindIndexKeyInfo+name)
+		if err != nil {
+			return nil, err
+		}
+
+		ans = append(ans, blindIndex(key, value, size))
+	}
+
+	return ans, nil
+}
+
+func (k *Keyring) keyIDs() []uint32 {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	ans := make([]uint32, 0, len(k.keys))
+
+	for id := range k.keys {
+		ans = append(ans, id)
+	}
+
+	sort.Slice(ans, func(i, j int) bool {
+		return ans[i] < ans[j]
+	})
+
+	return ans
+}
+
+func blindIndex(key, value []byte, size int) string {
+	h := hmac.New(sha256.New, key)
+	h.Write(value)
+
+	return hex.EncodeToString(h.Sum(nil)[:size])
+}
@@ -22,31 +22,49 @@ var (
 )
 
 func Encrypt(key string, data []byte) ([]byte, error) {
-	if len(key) != keySize {
-		return nil, errorsext.WithStack(ErrorInvalidKeySize)
-	}
+	return EncryptWithAD(key, data, nil)
+}
 
-	c, err := aes.NewCipher([]byte(key))
+func Decrypt(key string, data []byte) ([]byte, error) {
+	return DecryptWithAD(key, data, nil)
+}
+
+// EncryptWithAD encrypts data and binds it to additionalData, e.g. the
+// table and row id the value is stored in. additionalData is not part of
+// the output; the same value must be passed to DecryptWithAD, so a
+// ciphertext copied to another row fails to decrypt.
+func EncryptWithAD(key string, data, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
 	if err != nil {
-		return nil, errorsext.WithStack(err)
+		return nil, err
 	}
 
-	gcm, err := cipher.NewGCM(c)
+	return seal(gcm, nil, data, additionalData)
+}
+
+// DecryptWithAD decrypts data produced by EncryptWithAD.
+func DecryptWithAD(key string, data, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
 	if err != nil {
-		return nil, errorsext.WithStack(err)
+		return nil, err
 	}
 
-	nonce := make([]byte, gcm.NonceSize())
-	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
-		return nil, errorsext.WithStack(err)
-	}
+	return open(gcm, data, additionalData)
+}
+
+func Hash(data []byte) string {
+	h := sha256.New()
 
-	encrypted := gcm.Seal(nonce, nonce, data, nil)
+	h.Write(data)
 
-	return encrypted, nil
+	return hex.EncodeToString(h.Sum(nil))
 }
 
-func Decrypt(key string, data []byte) ([]byte, error) {
+func GenerateRandomString(size int) (string, error) {
+	return alphanumericGenerator.Generate(size)
+}
+
+func newGCM(key string) (cipher.AEAD, error) {
 	if len(key) != keySize {
 		return nil, errorsext.WithStack(ErrorInvalidKeySize)
 	}
@@ -61,35 +79,29 @@ func Decrypt(key string, data []byte) ([]byte, error) {
 		return nil, errorsext.WithStack(err)
 	}
 
-	nonceSize := gcm.NonceSize()
-	if len(data) < nonceSize {
-		return nil, errorsext.WithStack(ErrInvalidCipher)
-	}
-
-	nonce, ciphertext := data[:nonceSize], data[nonceSize:]
-
-	return gcm.Open(nil, nonce, ciphertext, nil)
+	return gcm, nil
 }
 
-func Hash(data []byte) string {
-	h := sha256.New()
+// seal appends nonce||ciphertext to dst using a random nonce.
+func seal(gcm cipher.AEAD, dst, data, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, errorsext.WithStack(err)
+	}
 
-	h.Write(data)
+	dst = append(dst, nonce...)
 
-	return hex.EncodeToString(h.Sum(nil))
+	return gcm.Seal(dst, nonce, data, additionalData), nil
 }
 
-func GenerateRandomString(size int) (string, error) {
-	const letters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-
-	b := make([]byte, size)
-	if _, err := rand.Read(b); err != nil {
-		return "", errorsext.WithStack(err)
+// open reverses seal, data must start with the nonce.
+func open(gcm cipher.AEAD, data, additionalData []byte) ([]byte, error) {
+	nonceSize := gcm.NonceSize()
+	if len(data) < nonceSize {
+		return nil, errorsext.WithStack(ErrInvalidCipher)
 	}
 
-	for i := range b {
-		b[i] = letters[int(b[i])%len(letters)]
-	}
+	nonce, ciphertext := data[:nonceSize], data[nonceSize:]
 
-	return string(b), nil
+	return gcm.Open(nil, nonce, ciphertext, additionalData)
 }
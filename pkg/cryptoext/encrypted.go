@@ -0,0 +1,163 @@
+package cryptoext
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/gosom/toolkit/pkg/errorsext"
+)
+
+var ErrEncryptedNotBound = errors.New("encrypted value is not bound to a keyring")
+
+// Encrypted holds a value of type T that is encrypted with a keyring
+// whenever it leaves the process: it implements driver.Valuer and
+// sql.Scanner so it can be used as a column type, and json.Marshaler so it
+// can be stored in JSON documents. The JSON form is the base64 encoded
+// ciphertext, not the value itself.
+//
+// The value is encoded as JSON before encryption. The additional data binds
+// the ciphertext to its row, e.g. "users:email:42" for the email column of
+// user 42, so it cannot be copied to another row or column. Values must be
+// bound with NewEncrypted or Bind before they are encrypted or decrypted.
+//
+// An Encrypted without a value, e.g. the zero value or one scanned from
+// NULL, is stored as NULL and marshaled as null; see Valid.
+type Encrypted[T any] struct {
+	value          T
+	valid          bool
+	keyring        *Keyring
+	additionalData []byte
+}
+
+// NewEncrypted wraps v, which is encrypted with k and additionalData.
+func NewEncrypted[T any](k *Keyring, additionalData []byte, v T) Encrypted[T] {
+	return Encrypted[T]{value: v, valid: true, keyring: k, additionalData: additionalData}
+}
+
+// Bind sets the keyring and the additional data, e.g. before scanning or
+// unmarshaling.
+func (e *Encrypted[T]) Bind(k *Keyring, additionalData []byte) {
+	e.keyring = k
+	e.additionalData = additionalData
+}
+
+// Get returns the plaintext value.
+func (e *Encrypted[T]) Get() T {
+	return e.value
+}
+
+// Set replaces the plaintext value.
+func (e *Encrypted[T]) Set(v T) {
+	e.value = v
+	e.valid = true
+}
+
+// Valid reports whether e holds a value. It is false for the zero value
+// and after scanning or unmarshaling a null.
+func (e *Encrypted[T]) Valid() bool {
+	return e.valid
+}
+
+// String does not reveal the value, so it is safe to log.
+func (e Encrypted[T]) String() string {
+	return "[encrypted]"
+}
+
+func (e Encrypted[T]) Value() (driver.Value, error) {
+	if !e.valid {
+		return nil, nil
+	}
+
+	return e.encrypt()
+}
+
+func (e *Encrypted[T]) Scan(src any) error {
+	var data []byte
+
+	switch v := src.(type) {
+	case nil:
+		var zero T
+
+		e.value = zero
+		e.valid = false
+
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return errorsext.WithStack(fmt.Errorf("cannot scan %T into Encrypted", src))
+	}
+
+	return e.decrypt(data)
+}
+
+func (e Encrypted[T]) MarshalJSON() ([]byte, error) {
+	if !e.valid {
+		return []byte("null"), nil
+	}
+
+	data, err := e.encrypt()
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(data)
+}
+
+func (e *Encrypted[T]) UnmarshalJSON(b []byte) error {
+	var data []byte
+
+	if err := json.Unmarshal(b, &data); err != nil {
+		return errorsext.WithStack(err)
+	}
+
+	if data == nil {
+		var zero T
+
+		e.value = zero
+		e.valid = false
+
+		return nil
+	}
+
+	return e.decrypt(data)
+}
+
+func (e *Encrypted[T]) encrypt() ([]byte, error) {
+	if e.keyring == nil {
+		return nil, errorsext.WithStack(ErrEncryptedNotBound)
+	}
+
+	plain, err := json.Marshal(e.value)
+	if err != nil {
+		return nil, errorsext.WithStack(err)
+	}
+
+	return e.keyring.EncryptWithAD(plain, e.additionalData)
+}
+
+func (e *Encrypted[T]) decrypt(data []byte) error {
+	if e.keyring == nil {
+		return errorsext.WithStack(ErrEncryptedNotBound)
+	}
+
+	plain, err := e.keyring.DecryptWithAD(data, e.additionalData)
+	if err != nil {
+		return errorsext.WithStack(err)
+	}
+
+	var v T
+
+	if err := json.Unmarshal(plain, &v); err != nil {
+		return errorsext.WithStack(err)
+	}
+
+	e.value = v
+	e.valid = true
+
+	return nil
+}
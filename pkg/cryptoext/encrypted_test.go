@@ -0,0 +1,109 @@
+package cryptoext_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/toolkit/pkg/cryptoext"
+)
+
+func Test_EncryptWithAD(t *testing.T) {
+	t.Parallel()
+
+	const key = "12345678901234567890123456789012"
+
+	encrypted, err := cryptoext.EncryptWithAD(key, []byte("123-45-6789"), []byte("users:1"))
+	require.NoError(t, err)
+
+	plain, err := cryptoext.DecryptWithAD(key, encrypted, []byte("users:1"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("123-45-6789"), plain)
+
+	_, err = cryptoext.DecryptWithAD(key, encrypted, []byte("users:2"))
+	require.Error(t, err)
+
+	kr := cryptoext.NewKeyring()
+	require.NoError(t, kr.Add(1, key))
+
+	encrypted, err = kr.EncryptWithAD([]byte("123-45-6789"), []byte("users:1"))
+	require.NoError(t, err)
+
+	_, err = kr.DecryptWithAD(encrypted, []byte("users:2"))
+	require.Error(t, err)
+
+	plain, err = kr.DecryptWithAD(encrypted, []byte("users:1"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("123-45-6789"), plain)
+}
+
+func Test_Encrypted(t *testing.T) {
+	t.Parallel()
+
+	kr := cryptoext.NewKeyring()
+	require.NoError(t, kr.Add(1, "12345678901234567890123456789012"))
+
+	type address struct {
+		Street string `json:"street"`
+	}
+
+	type user struct {
+		Email   cryptoext.Encrypted[string]  `json:"email"`
+		Address cryptoext.Encrypted[address] `json:"address"`
+	}
+
+	u := user{
+		Email:   cryptoext.NewEncrypted(kr, []byte("users:email:1"), "john@example.com"),
+		Address: cryptoext.NewEncrypted(kr, []byte("users:address:1"), address{Street: "Main St"}),
+	}
+
+	b, err := json.Marshal(u)
+	require.NoError(t, err)
+	require.NotContains(t, string(b), "john@example.com")
+
+	var decoded user
+
+	decoded.Email.Bind(kr, []byte("users:email:1"))
+	decoded.Address.Bind(kr, []byte("users:address:1"))
+	require.NoError(t, json.Unmarshal(b, &decoded))
+	require.Equal(t, "john@example.com", decoded.Email.Get())
+	require.Equal(t, "Main St", decoded.Address.Get().Street)
+
+	v, err := u.Email.Value()
+	require.NoError(t, err)
+
+	var scanned cryptoext.Encrypted[string]
+	require.ErrorIs(t, scanned.Scan(v), cryptoext.ErrEncryptedNotBound)
+
+	// the value cannot be moved to another row
+	scanned.Bind(kr, []byte("users:email:2"))
+	require.Error(t, scanned.Scan(v))
+
+	scanned.Bind(kr, []byte("users:email:1"))
+	require.NoError(t, scanned.Scan(v))
+	require.Equal(t, "john@example.com", scanned.Get())
+	require.Equal(t, "[encrypted]", scanned.String())
+
+	_, err = cryptoext.NewEncrypted(nil, nil, "john@example.com").Value()
+	require.ErrorIs(t, err, cryptoext.ErrEncryptedNotBound)
+
+	// NULL round trips
+	require.NoError(t, scanned.Scan(nil))
+	require.False(t, scanned.Valid())
+
+	v, err = scanned.Value()
+	require.NoError(t, err)
+	require.Nil(t, v)
+
+	v, err = cryptoext.Encrypted[string]{}.Value()
+	require.NoError(t, err)
+	require.Nil(t, v)
+
+	b, err = json.Marshal(user{})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"email":null,"address":null}`, string(b))
+
+	require.NoError(t, json.Unmarshal(b, &decoded))
+	require.False(t, decoded.Email.Valid())
+}
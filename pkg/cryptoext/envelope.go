@@ -0,0 +1,264 @@
+package cryptoext
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"os"
+
+	"github.com/gosom/toolkit/pkg/errorsext"
+)
+
+const envelopeVersion byte = 1
+
+var (
+	ErrUnknownKEK      = errors.New("unknown key encryption key")
+	ErrInvalidKEKID    = errors.New("key encryption key id must be between 1 and 255 bytes")
+	ErrInvalidEnvelope = errors.New("invalid envelope")
+)
+
+// KeyEncryptionKey wraps and unwraps data keys. LocalKEK keeps the master
+// key in memory; implementations backed by a cloud KMS only need to call
+// the provider's encrypt and decrypt operations.
+type KeyEncryptionKey interface {
+	// ID identifies the key and is stored next to every wrapped data key.
+	ID() string
+	WrapKey(ctx context.Context, dataKey []byte) ([]byte, error)
+	UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error)
+}
+
+// LocalKEK is a KeyEncryptionKey backed by a local master key.
+type LocalKEK struct {
+	id  string
+	key string
+}
+
+// NewLocalKEK creates a LocalKEK. The key has the same format as the one used by Encrypt.
+func NewLocalKEK(id, key string) (*LocalKEK, error) {
+	if id == "" || len(id) > math.MaxUint8 {
+		return nil, errorsext.WithStack(ErrInvalidKEKID)
+	}
+
+	if len(key) != keySize {
+		return nil, errorsext.WithStack(ErrorInvalidKeySize)
+	}
+
+	return &LocalKEK{id: id, key: key}, nil
+}
+
+// LoadLocalKEK reads the master key from a file. The file contains either
+// the key itself or its standard base64 encoding; surrounding whitespace is ignored.
+func LoadLocalKEK(id, path string) (*LocalKEK, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errorsext.WithStack(err)
+	}
+
+	content = bytes.TrimSpace(content)
+
+	if len(content) != keySize {
+		decoded, err := base64.StdEncoding.DecodeString(string(content))
+		if err == nil {
+			content = decoded
+		}
+	}
+
+	return NewLocalKEK(id, string(content))
+}
+
+func (k *LocalKEK) ID() string {
+	return k.id
+}
+
+func (k *LocalKEK) WrapKey(_ context.Context, dataKey []byte) ([]byte, error) {
+	gcm, err := newGCM(k.key)
+	if err != nil {
+		return nil, err
+	}
+
+	return seal(gcm, nil, dataKey, []byte(k.id))
+}
+
+func (k *LocalKEK) UnwrapKey(_ context.Context, wrapped []byte) ([]byte, error) {
+	gcm, err := newGCM(k.key)
+	if err != nil {
+		return nil, err
+	}
+
+	ans, err := open(gcm, wrapped, []byte(k.id))
+	if err != nil {
+		return nil, errorsext.WithStack(err)
+	}
+
+	return ans, nil
+}
+
+// Envelope implements envelope encryption: every object is encrypted with
+// a fresh data key using Encrypt, and the data key is stored next to the
+// ciphertext wrapped by a KeyEncryptionKey.
+//
+// The output has the following layout:
+//
+//	version (1 byte) | kek id length (1 byte) | kek id |
+//	wrapped key length (2 bytes, big endian) | wrapped key | Encrypt output
+type Envelope struct {
+	primary KeyEncryptionKey
+	keks    map[string]KeyEncryptionKey
+}
+
+// NewEnvelope creates an Envelope that wraps new data keys with primary.
+// Data wrapped by any of the older KEKs can still be decrypted.
+func NewEnvelope(primary KeyEncryptionKey, older ...KeyEncryptionKey) *Envelope {
+	ans := Envelope{
+		primary: primary,
+		keks:    make(map[string]KeyEncryptionKey, len(older)+1),
+	}
+
+	for _, k := range older {
+		ans.keks[k.ID()] = k
+	}
+
+	ans.keks[primary.ID()] = primary
+
+	return &ans
+}
+
+// GenerateDataKey returns a new data key, usable with Encrypt and Decrypt,
+// together with its wrapped form.
+func (e *Envelope) GenerateDataKey(ctx context.Context) (dataKey string, wrapped []byte, err error) {
+	dk := make([]byte, keySize)
+	if _, err := io.ReadFull(rand.Reader, dk); err != nil {
+		return "", nil, errorsext.WithStack(err)
+	}
+
+	wrapped, err = e.primary.WrapKey(ctx, dk)
+	if err != nil {
+		return "", nil, errorsext.WithStack(err)
+	}
+
+	return string(dk), wrapped, nil
+}
+
+// Encrypt encrypts data with a new data key.
+func (e *Envelope) Encrypt(ctx context.Context, data []byte) ([]byte, error) {
+	dataKey, wrapped, err := e.GenerateDataKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted, err := Encrypt(dataKey, data)
+	if err != nil {
+		return nil, err
+	}
+
+	header, err := envelopeHeader(e.primary.ID(), wrapped)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(header, encrypted...), nil
+}
+
+// Decrypt decrypts data produced by Encrypt.
+func (e *Envelope) Decrypt(ctx context.Context, data []byte) ([]byte, error) {
+	kekID, wrapped, encrypted, err := parseEnvelope(data)
+	if err != nil {
+		return nil, err
+	}
+
+	kek, ok := e.keks[kekID]
+	if !ok {
+		return nil, errorsext.WithStack(ErrUnknownKEK)
+	}
+
+	dataKey, err := kek.UnwrapKey(ctx, wrapped)
+	if err != nil {
+		return nil, errorsext.WithStack(err)
+	}
+
+	return Decrypt(string(dataKey), encrypted)
+}
+
+// Rewrap wraps the data key of data with the primary KEK. The ciphertext
+// itself is not touched, which makes rotating the master key cheap.
+func (e *Envelope) Rewrap(ctx context.Context, data []byte) ([]byte, error) {
+	kekID, wrapped, encrypted, err := parseEnvelope(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if kekID == e.primary.ID() {
+		return data, nil
+	}
+
+	kek, ok := e.keks[kekID]
+	if !ok {
+		return nil, errorsext.WithStack(ErrUnknownKEK)
+	}
+
+	dataKey, err := kek.UnwrapKey(ctx, wrapped)
+	if err != nil {
+		return nil, errorsext.WithStack(err)
+	}
+
+	wrapped, err = e.primary.WrapKey(ctx, dataKey)
+	if err != nil {
+		return nil, errorsext.WithStack(err)
+	}
+
+	header, err := envelopeHeader(e.primary.ID(), wrapped)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(header, encrypted...), nil
+}
+
+func envelopeHeader(kekID string, wrapped []byte) ([]byte, error) {
+	if kekID == "" || len(kekID) > math.MaxUint8 {
+		return nil, errorsext.WithStack(ErrInvalidKEKID)
+	}
+
+	if len(wrapped) > math.MaxUint16 {
+		return nil, errorsext.WithStack(ErrInvalidEnvelope)
+	}
+
+	ans := make([]byte, 0, 4+len(kekID)+len(wrapped))
+
+	ans = append(ans, envelopeVersion, byte(len(kekID)))
+	ans = append(ans, kekID...)
+	ans = binary.BigEndian.AppendUint16(ans, uint16(len(wrapped)))
+	ans = append(ans, wrapped...)
+
+	return ans, nil
+}
+
+func parseEnvelope(data []byte) (kekID string, wrapped, encrypted []byte, err error) {
+	if len(data) < 2 || data[0] != envelopeVersion {
+		return "", nil, nil, errorsext.WithStack(ErrInvalidEnvelope)
+	}
+
+	idLen := int(data[1])
+	data = data[2:]
+
+	if len(data) < idLen+2 {
+		return "", nil, nil, errorsext.WithStack(ErrInvalidEnvelope)
+	}
+
+	kekID = string(data[:idLen])
+	data = data[idLen:]
+
+	wrappedLen := int(binary.BigEndian.Uint16(data))
+	data = data[2:]
+
+	if len(data) < wrappedLen {
+		return "", nil, nil, errorsext.WithStack(ErrInvalidEnvelope)
+	}
+
+	return kekID, data[:wrappedLen], data[wrappedLen:], nil
+}
@@ -0,0 +1,49 @@
+package cryptoext_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/toolkit/pkg/cryptoext"
+)
+
+func Test_Envelope(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	oldKEK, err := cryptoext.NewLocalKEK("master-1", "12345678901234567890123456789012")
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "master.key")
+	require.NoError(t, os.WriteFile(path, []byte("abcdefghijklmnopqrstuvwxyzabcdef\n"), 0o600))
+
+	newKEK, err := cryptoext.LoadLocalKEK("master-2", path)
+	require.NoError(t, err)
+
+	encrypted, err := cryptoext.NewEnvelope(oldKEK).Encrypt(ctx, []byte("tenant data"))
+	require.NoError(t, err)
+
+	env := cryptoext.NewEnvelope(newKEK, oldKEK)
+
+	plain, err := env.Decrypt(ctx, encrypted)
+	require.NoError(t, err)
+	require.Equal(t, []byte("tenant data"), plain)
+
+	rewrapped, err := env.Rewrap(ctx, encrypted)
+	require.NoError(t, err)
+
+	plain, err = cryptoext.NewEnvelope(newKEK).Decrypt(ctx, rewrapped)
+	require.NoError(t, err)
+	require.Equal(t, []byte("tenant data"), plain)
+
+	_, err = cryptoext.NewEnvelope(newKEK).Decrypt(ctx, encrypted)
+	require.ErrorIs(t, err, cryptoext.ErrUnknownKEK)
+
+	_, err = env.Decrypt(ctx, encrypted[:3])
+	require.ErrorIs(t, err, cryptoext.ErrInvalidEnvelope)
+}
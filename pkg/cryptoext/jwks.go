@@ -0,0 +1,288 @@
+package cryptoext
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+
+	"github.com/gosom/toolkit/pkg/errorsext"
+)
+
+var ErrInvalidJWK = errors.New("invalid jwk")
+
+// JWK is a public JSON Web Key (RFC 7517).
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid,omitempty"`
+	Use       string `json:"use,omitempty"`
+	Algorithm string `json:"alg,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set. It implements http.Handler so it can be
+// served directly, e.g. at /.well-known/jwks.json.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewJWKS builds a key set from the public part of keys.
+// HS256 keys are secrets and cannot be published.
+func NewJWKS(keys ...JWTKey) (*JWKS, error) {
+	ans := JWKS{
+		Keys: make([]JWK, 0, len(keys)),
+	}
+
+	for _, k := range keys {
+		jwk, err := newJWK(k)
+		if err != nil {
+			return nil, err
+		}
+
+		ans.Keys = append(ans.Keys, jwk)
+	}
+
+	return &ans, nil
+}
+
+// ReadJWKS decodes a key set from r.
+func ReadJWKS(r io.Reader) (*JWKS, error) {
+	var ans JWKS
+
+	if err := json.NewDecoder(r).Decode(&ans); err != nil {
+		return nil, errorsext.WithStack(fmt.Errorf("%w: %w", ErrInvalidJWK, err))
+	}
+
+	return &ans, nil
+}
+
+// ReadJWKSFile reads a key set from a local file.
+func ReadJWKSFile(path string) (*JWKS, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errorsext.WithStack(err)
+	}
+
+	defer f.Close()
+
+	return ReadJWKS(f)
+}
+
+// FetchJWKS fetches the key set published at url with client, or
+// http.DefaultClient if client is nil.
+func FetchJWKS(ctx context.Context, client *http.Client, url string) (*JWKS, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
+	if err != nil {
+		return nil, errorsext.WithStack(err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errorsext.WithStack(err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errorsext.WithStack(fmt.Errorf("%w: unexpected status %d", ErrInvalidJWK, resp.StatusCode))
+	}
+
+	return ReadJWKS(resp.Body)
+}
+
+// ReadJWKSFromHandler reads the key set served by h, which is useful when
+// the issuer runs in the same process.
+func ReadJWKSFromHandler(h http.Handler) (*JWKS, error) {
+	req, err := http.NewRequest(http.MethodGet, "/", http.NoBody)
+	if err != nil {
+		return nil, errorsext.WithStack(err)
+	}
+
+	rw := responseBuffer{header: http.Header{}}
+
+	h.ServeHTTP(&rw, req)
+
+	if rw.status != 0 && rw.status != http.StatusOK {
+		return nil, errorsext.WithStack(fmt.Errorf("%w: unexpected status %d", ErrInvalidJWK, rw.status))
+	}
+
+	return ReadJWKS(&rw.body)
+}
+
+// responseBuffer is a minimal http.ResponseWriter keeping the response in memory.
+type responseBuffer struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *responseBuffer) Header() http.Header {
+	return w.header
+}
+
+func (w *responseBuffer) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	return w.body.Write(b)
+}
+
+func (w *responseBuffer) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (s *JWKS) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+
+	_ = json.NewEncoder(w).Encode(s)
+}
+
+// JWTKeys converts the key set to verification keys.
+func (s *JWKS) JWTKeys() ([]JWTKey, error) {
+	ans := make([]JWTKey, 0, len(s.Keys))
+
+	for i := range s.Keys {
+		k, err := s.Keys[i].JWTKey()
+		if err != nil {
+			return nil, err
+		}
+
+		ans = append(ans, k)
+	}
+
+	return ans, nil
+}
+
+// WithJWKS adds all the keys of set to the verifier.
+func WithJWKS(set *JWKS) JWTVerifierOption {
+	return func(v *JWTVerifier) error {
+		keys, err := set.JWTKeys()
+		if err != nil {
+			return err
+		}
+
+		return WithJWTKeys(keys...)(v)
+	}
+}
+
+// JWTKey converts the JWK to a verification key.
+func (k *JWK) JWTKey() (JWTKey, error) {
+	ans := JWTKey{
+		ID:        k.KeyID,
+		Algorithm: JWTAlgorithm(k.Algorithm),
+	}
+
+	switch k.KeyType {
+	case "RSA":
+		n, err1 := base64.RawURLEncoding.DecodeString(k.N)
+		e, err2 := base64.RawURLEncoding.DecodeString(k.E)
+
+		exp := new(big.Int).SetBytes(e)
+		if err1 != nil || err2 != nil || !exp.IsInt64() {
+			return ans, errorsext.WithStack(ErrInvalidJWK)
+		}
+
+		ans.Key = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}
+
+		if ans.Algorithm == "" {
+			ans.Algorithm = RS256
+		}
+	case "EC":
+		x, err1 := base64.RawURLEncoding.DecodeString(k.X)
+		y, err2 := base64.RawURLEncoding.DecodeString(k.Y)
+
+		if err1 != nil || err2 != nil || k.Curve != "P-256" {
+			return ans, errorsext.WithStack(ErrInvalidJWK)
+		}
+
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return ans, errorsext.WithStack(ErrInvalidJWK)
+		}
+
+		ans.Key = pub
+
+		if ans.Algorithm == "" {
+			ans.Algorithm = ES256
+		}
+	case "OKP":
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || k.Curve != "Ed25519" {
+			return ans, errorsext.WithStack(ErrInvalidJWK)
+		}
+
+		ans.Key = ed25519.PublicKey(x)
+
+		if ans.Algorithm == "" {
+			ans.Algorithm = EdDSA
+		}
+	default:
+		return ans, errorsext.WithStack(ErrInvalidJWK)
+	}
+
+	if err := validateJWTKey(ans, false); err != nil {
+		return ans, err
+	}
+
+	return ans, nil
+}
+
+func newJWK(key JWTKey) (JWK, error) {
+	if err := validateJWTKey(key, false); err != nil {
+		return JWK{}, err
+	}
+
+	ans := JWK{
+		KeyID:     key.ID,
+		Use:       "sig",
+		Algorithm: string(key.Algorithm),
+	}
+
+	pub := key.Key
+	if k, ok := pub.(crypto.Signer); ok {
+		pub = k.Public()
+	}
+
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		ans.KeyType = "RSA"
+		ans.N = base64.RawURLEncoding.EncodeToString(k.N.Bytes())
+		ans.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes())
+	case *ecdsa.PublicKey:
+		ans.KeyType = "EC"
+		ans.Curve = "P-256"
+		ans.X = base64.RawURLEncoding.EncodeToString(k.X.FillBytes(make([]byte, es256KeySize)))
+		ans.Y = base64.RawURLEncoding.EncodeToString(k.Y.FillBytes(make([]byte, es256KeySize)))
+	case ed25519.PublicKey:
+		ans.KeyType = "OKP"
+		ans.Curve = "Ed25519"
+		ans.X = base64.RawURLEncoding.EncodeToString(k)
+	default:
+		return JWK{}, errorsext.WithStack(ErrJWTInvalidKey)
+	}
+
+	return ans, nil
+}
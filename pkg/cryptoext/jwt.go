@@ -0,0 +1,513 @@
+package cryptoext
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gosom/toolkit/pkg/errorsext"
+)
+
+type JWTAlgorithm string
+
+const (
+	HS256 JWTAlgorithm = "HS256"
+	RS256 JWTAlgorithm = "RS256"
+	ES256 JWTAlgorithm = "ES256"
+	EdDSA JWTAlgorithm = "EdDSA"
+)
+
+const (
+	es256KeySize  = 32
+	minHMACKeyLen = 32
+	minRSAKeyBits = 2048
+	jwtSegments   = 3
+)
+
+var (
+	ErrJWTMalformed       = errors.New("malformed jwt")
+	ErrJWTSignature       = errors.New("invalid jwt signature")
+	ErrJWTUnknownKey      = errors.New("unknown jwt key")
+	ErrJWTAlgorithm       = errors.New("unsupported or unexpected jwt algorithm")
+	ErrJWTInvalidKey      = errors.New("invalid key for jwt algorithm")
+	ErrJWTExpired         = errors.New("jwt expired")
+	ErrJWTNotYetValid     = errors.New("jwt not yet valid")
+	ErrJWTInvalidIssuer   = errors.New("invalid jwt issuer")
+	ErrJWTInvalidAudience = errors.New("invalid jwt audience")
+)
+
+// Audience is the aud claim. It is encoded as a string when it holds a
+// single value and as an array otherwise.
+type Audience []string
+
+func (a Audience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+
+	return json.Marshal([]string(a))
+}
+
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = Audience{single}
+
+		return nil
+	}
+
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+
+	*a = many
+
+	return nil
+}
+
+// RegisteredClaims are the standard claims of RFC 7519.
+// Times are expressed in seconds since the unix epoch.
+type RegisteredClaims struct {
+	Issuer    string   `json:"iss,omitempty"`
+	Subject   string   `json:"sub,omitempty"`
+	Audience  Audience `json:"aud,omitempty"`
+	ExpiresAt int64    `json:"exp,omitempty"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	ID        string   `json:"jti,omitempty"`
+}
+
+// Claims combines the registered claims with custom claims of type T.
+// T must encode to a JSON object; its fields are flattened next to the
+// registered claims in the token payload.
+type Claims[T any] struct {
+	RegisteredClaims
+	Custom T
+}
+
+func (c Claims[T]) MarshalJSON() ([]byte, error) {
+	registered, err := json.Marshal(c.RegisteredClaims)
+	if err != nil {
+		return nil, err
+	}
+
+	custom, err := json.Marshal(c.Custom)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := map[string]json.RawMessage{}
+
+	if !bytes.Equal(custom, []byte("null")) {
+		if err := json.Unmarshal(custom, &merged); err != nil {
+			return nil, fmt.Errorf("custom claims must be a json object: %w", err)
+		}
+	}
+
+	if err := json.Unmarshal(registered, &merged); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(merged)
+}
+
+func (c *Claims[T]) UnmarshalJSON(data []byte) error {
+	if err := unmarshalRegisteredClaims(data, &c.RegisteredClaims); err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, &c.Custom)
+}
+
+// unmarshalRegisteredClaims decodes the registered claims of data. Times
+// with a fraction of a second, which RFC 7519 allows, are truncated.
+func unmarshalRegisteredClaims(data []byte, c *RegisteredClaims) error {
+	type registeredClaims RegisteredClaims
+
+	aux := struct {
+		*registeredClaims
+		ExpiresAt json.Number `json:"exp,omitempty"`
+		NotBefore json.Number `json:"nbf,omitempty"`
+		IssuedAt  json.Number `json:"iat,omitempty"`
+	}{
+		registeredClaims: (*registeredClaims)(c),
+	}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	for _, e := range []struct {
+		src json.Number
+		dst *int64
+	}{
+		{aux.ExpiresAt, &c.ExpiresAt},
+		{aux.NotBefore, &c.NotBefore},
+		{aux.IssuedAt, &c.IssuedAt},
+	} {
+		if e.src == "" {
+			continue
+		}
+
+		if v, err := e.src.Int64(); err == nil {
+			*e.dst = v
+
+			continue
+		}
+
+		f, err := e.src.Float64()
+		if err != nil || f < math.MinInt64 || f >= math.MaxInt64 {
+			return fmt.Errorf("invalid numeric date %s", e.src)
+		}
+
+		*e.dst = int64(math.Floor(f))
+	}
+
+	return nil
+}
+
+// JWTKey is a key used to sign or verify tokens.
+//
+// Key holds a []byte secret for HS256, an *rsa.PrivateKey or *rsa.PublicKey
+// for RS256, an *ecdsa.PrivateKey or *ecdsa.PublicKey on P-256 for ES256
+// and an ed25519.PrivateKey or ed25519.PublicKey for EdDSA.
+type JWTKey struct {
+	ID        string
+	Algorithm JWTAlgorithm
+	Key       any
+}
+
+type jwtHeader struct {
+	Algorithm JWTAlgorithm `json:"alg"`
+	Type      string       `json:"typ,omitempty"`
+	KeyID     string       `json:"kid,omitempty"`
+}
+
+// JWTSigner issues tokens with a single private key.
+type JWTSigner struct {
+	key JWTKey
+}
+
+// NewJWTSigner creates a JWTSigner. The key must be a secret or a private key.
+func NewJWTSigner(key JWTKey) (*JWTSigner, error) {
+	if err := validateJWTKey(key, true); err != nil {
+		return nil, err
+	}
+
+	return &JWTSigner{key: key}, nil
+}
+
+// SignJWT returns the signed compact serialization of claims.
+func SignJWT[T any](s *JWTSigner, claims Claims[T]) (string, error) {
+	header, err := json.Marshal(jwtHeader{
+		Algorithm: s.key.Algorithm,
+		Type:      "JWT",
+		KeyID:     s.key.ID,
+	})
+	if err != nil {
+		return "", errorsext.WithStack(err)
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", errorsext.WithStack(err)
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." +
+		base64.RawURLEncoding.EncodeToString(payload)
+
+	sig, err := jwtSign(s.key, []byte(signingInput))
+	if err != nil {
+		return "", err
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+type JWTVerifierOption func(*JWTVerifier) error
+
+// WithJWTIssuer requires the iss claim to equal issuer.
+func WithJWTIssuer(issuer string) JWTVerifierOption {
+	return func(v *JWTVerifier) error {
+		v.issuer = issuer
+
+		return nil
+	}
+}
+
+// WithJWTAudience requires the aud claim to contain audience.
+func WithJWTAudience(audience string) JWTVerifierOption {
+	return func(v *JWTVerifier) error {
+		v.audience = audience
+
+		return nil
+	}
+}
+
+// WithJWTLeeway sets the allowed clock skew when validating exp and nbf.
+func WithJWTLeeway(leeway time.Duration) JWTVerifierOption {
+	return func(v *JWTVerifier) error {
+		v.leeway = leeway
+
+		return nil
+	}
+}
+
+// WithJWTKeys adds verification keys.
+func WithJWTKeys(keys ...JWTKey) JWTVerifierOption {
+	return func(v *JWTVerifier) error {
+		for _, k := range keys {
+			if err := v.AddKey(k); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+// JWTVerifier verifies token signatures and validates the registered claims.
+// Keys are selected by the kid header and each key only accepts tokens
+// signed with its own algorithm.
+type JWTVerifier struct {
+	mu       sync.RWMutex
+	keys     map[string]JWTKey
+	issuer   string
+	audience string
+	leeway   time.Duration
+}
+
+// NewJWTVerifier creates a JWTVerifier.
+func NewJWTVerifier(opts ...JWTVerifierOption) (*JWTVerifier, error) {
+	ans := JWTVerifier{
+		keys: make(map[string]JWTKey),
+	}
+
+	for _, opt := range opts {
+		if err := opt(&ans); err != nil {
+			return nil, errorsext.WithStack(err)
+		}
+	}
+
+	return &ans, nil
+}
+
+// AddKey adds a verification key. Private keys are reduced to their public part.
+// Adding a key with an existing id replaces it.
+func (v *JWTVerifier) AddKey(key JWTKey) error {
+	if err := validateJWTKey(key, false); err != nil {
+		return err
+	}
+
+	if signer, ok := key.Key.(crypto.Signer); ok {
+		key.Key = signer.Public()
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.keys[key.ID] = key
+
+	return nil
+}
+
+// ParseJWT verifies token and returns its claims.
+func ParseJWT[T any](v *JWTVerifier, token string) (Claims[T], error) {
+	var claims Claims[T]
+
+	parts := strings.Split(token, ".")
+	if len(parts) != jwtSegments {
+		return claims, errorsext.WithStack(ErrJWTMalformed)
+	}
+
+	var header jwtHeader
+
+	if err := decodeJWTSegment(parts[0], &header); err != nil {
+		return claims, err
+	}
+
+	v.mu.RLock()
+	key, ok := v.keys[header.KeyID]
+	v.mu.RUnlock()
+
+	if !ok {
+		return claims, errorsext.WithStack(ErrJWTUnknownKey)
+	}
+
+	if header.Algorithm != key.Algorithm {
+		return claims, errorsext.WithStack(ErrJWTAlgorithm)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return claims, errorsext.WithStack(ErrJWTMalformed)
+	}
+
+	if err := jwtVerify(key, []byte(parts[0]+"."+parts[1]), sig); err != nil {
+		return claims, err
+	}
+
+	if err := decodeJWTSegment(parts[1], &claims); err != nil {
+		return claims, err
+	}
+
+	if err := v.validate(&claims.RegisteredClaims, time.Now()); err != nil {
+		return claims, err
+	}
+
+	return claims, nil
+}
+
+func (v *JWTVerifier) validate(c *RegisteredClaims, now time.Time) error {
+	if c.ExpiresAt != 0 && now.Add(-v.leeway).Unix() >= c.ExpiresAt {
+		return errorsext.WithStack(ErrJWTExpired)
+	}
+
+	if c.NotBefore != 0 && now.Add(v.leeway).Unix() < c.NotBefore {
+		return errorsext.WithStack(ErrJWTNotYetValid)
+	}
+
+	if v.issuer != "" && c.Issuer != v.issuer {
+		return errorsext.WithStack(ErrJWTInvalidIssuer)
+	}
+
+	if v.audience != "" && !slices.Contains(c.Audience, v.audience) {
+		return errorsext.WithStack(ErrJWTInvalidAudience)
+	}
+
+	return nil
+}
+
+func decodeJWTSegment(segment string, dst any) error {
+	raw, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return errorsext.WithStack(ErrJWTMalformed)
+	}
+
+	if err := json.Unmarshal(raw, dst); err != nil {
+		return errorsext.WithStack(fmt.Errorf("%w: %w", ErrJWTMalformed, err))
+	}
+
+	return nil
+}
+
+func validateJWTKey(key JWTKey, private bool) error {
+	var ok bool
+
+	switch key.Algorithm {
+	case HS256:
+		var secret []byte
+
+		secret, ok = key.Key.([]byte)
+		ok = ok && len(secret) >= minHMACKeyLen
+	case RS256:
+		switch k := key.Key.(type) {
+		case *rsa.PrivateKey:
+			ok = k.N.BitLen() >= minRSAKeyBits
+		case *rsa.PublicKey:
+			ok = !private && k.N.BitLen() >= minRSAKeyBits
+		}
+	case ES256:
+		switch k := key.Key.(type) {
+		case *ecdsa.PrivateKey:
+			ok = k.Curve == elliptic.P256()
+		case *ecdsa.PublicKey:
+			ok = !private && k.Curve == elliptic.P256()
+		}
+	case EdDSA:
+		switch k := key.Key.(type) {
+		case ed25519.PrivateKey:
+			ok = len(k) == ed25519.PrivateKeySize
+		case ed25519.PublicKey:
+			ok = !private && len(k) == ed25519.PublicKeySize
+		}
+	default:
+		return errorsext.WithStack(ErrJWTAlgorithm)
+	}
+
+	if !ok {
+		return errorsext.WithStack(ErrJWTInvalidKey)
+	}
+
+	return nil
+}
+
+func jwtSign(key JWTKey, input []byte) ([]byte, error) {
+	digest := sha256.Sum256(input)
+
+	switch key.Algorithm {
+	case HS256:
+		h := hmac.New(sha256.New, key.Key.([]byte))
+		h.Write(input)
+
+		return h.Sum(nil), nil
+	case RS256:
+		sig, err := rsa.SignPKCS1v15(rand.Reader, key.Key.(*rsa.PrivateKey), crypto.SHA256, digest[:])
+
+		return sig, errorsext.WithStack(err)
+	case ES256:
+		r, s, err := ecdsa.Sign(rand.Reader, key.Key.(*ecdsa.PrivateKey), digest[:])
+		if err != nil {
+			return nil, errorsext.WithStack(err)
+		}
+
+		sig := make([]byte, 2*es256KeySize)
+		r.FillBytes(sig[:es256KeySize])
+		s.FillBytes(sig[es256KeySize:])
+
+		return sig, nil
+	case EdDSA:
+		return ed25519.Sign(key.Key.(ed25519.PrivateKey), input), nil
+	default:
+		return nil, errorsext.WithStack(ErrJWTAlgorithm)
+	}
+}
+
+func jwtVerify(key JWTKey, input, sig []byte) error {
+	digest := sha256.Sum256(input)
+
+	var ok bool
+
+	switch key.Algorithm {
+	case HS256:
+		h := hmac.New(sha256.New, key.Key.([]byte))
+		h.Write(input)
+
+		ok = hmac.Equal(sig, h.Sum(nil))
+	case RS256:
+		ok = rsa.VerifyPKCS1v15(key.Key.(*rsa.PublicKey), crypto.SHA256, digest[:], sig) == nil
+	case ES256:
+		if len(sig) == 2*es256KeySize {
+			r := new(big.Int).SetBytes(sig[:es256KeySize])
+			s := new(big.Int).SetBytes(sig[es256KeySize:])
+			ok = ecdsa.Verify(key.Key.(*ecdsa.PublicKey), digest[:], r, s)
+		}
+	case EdDSA:
+		ok = ed25519.Verify(key.Key.(ed25519.PublicKey), input, sig)
+	default:
+		return errorsext.WithStack(ErrJWTAlgorithm)
+	}
+
+	if !ok {
+		return errorsext.WithStack(ErrJWTSignature)
+	}
+
+	return nil
+}
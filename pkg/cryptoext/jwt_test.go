@@ -0,0 +1,180 @@
+package cryptoext_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/toolkit/pkg/cryptoext"
+)
+
+type testClaims struct {
+	Role string `json:"role"`
+}
+
+func Test_JWT_Algorithms(t *testing.T) {
+	t.Parallel()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	keys := []cryptoext.JWTKey{
+		{ID: "hs", Algorithm: cryptoext.HS256, Key: []byte("12345678901234567890123456789012")},
+		{ID: "rs", Algorithm: cryptoext.RS256, Key: rsaKey},
+		{ID: "es", Algorithm: cryptoext.ES256, Key: ecKey},
+		{ID: "ed", Algorithm: cryptoext.EdDSA, Key: edKey},
+	}
+
+	verifier, err := cryptoext.NewJWTVerifier(
+		cryptoext.WithJWTKeys(keys...),
+		cryptoext.WithJWTIssuer("toolkit"),
+		cryptoext.WithJWTAudience("api"),
+	)
+	require.NoError(t, err)
+
+	for _, key := range keys {
+		signer, err := cryptoext.NewJWTSigner(key)
+		require.NoError(t, err)
+
+		token, err := cryptoext.SignJWT(signer, cryptoext.Claims[testClaims]{
+			RegisteredClaims: cryptoext.RegisteredClaims{
+				Issuer:    "toolkit",
+				Subject:   "42",
+				Audience:  cryptoext.Audience{"api"},
+				ExpiresAt: time.Now().Add(time.Minute).Unix(),
+			},
+			Custom: testClaims{Role: "admin"},
+		})
+		require.NoError(t, err)
+
+		claims, err := cryptoext.ParseJWT[testClaims](verifier, token)
+		require.NoError(t, err, key.Algorithm)
+		require.Equal(t, "42", claims.Subject)
+		require.Equal(t, "admin", claims.Custom.Role)
+	}
+}
+
+func Test_JWT_ClaimValidation(t *testing.T) {
+	t.Parallel()
+
+	key := cryptoext.JWTKey{ID: "hs", Algorithm: cryptoext.HS256, Key: []byte("12345678901234567890123456789012")}
+
+	signer, err := cryptoext.NewJWTSigner(key)
+	require.NoError(t, err)
+
+	verifier, err := cryptoext.NewJWTVerifier(
+		cryptoext.WithJWTKeys(key),
+		cryptoext.WithJWTAudience("api"),
+		cryptoext.WithJWTLeeway(30*time.Second),
+	)
+	require.NoError(t, err)
+
+	now := time.Now()
+
+	tests := []struct {
+		name   string
+		claims cryptoext.RegisteredClaims
+		err    error
+	}{
+		{"within leeway", cryptoext.RegisteredClaims{Audience: cryptoext.Audience{"api"}, ExpiresAt: now.Add(-10 * time.Second).Unix()}, nil},
+		{"expired", cryptoext.RegisteredClaims{Audience: cryptoext.Audience{"api"}, ExpiresAt: now.Add(-time.Minute).Unix()}, cryptoext.ErrJWTExpired},
+		{"not yet valid", cryptoext.RegisteredClaims{Audience: cryptoext.Audience{"api"}, NotBefore: now.Add(time.Minute).Unix()}, cryptoext.ErrJWTNotYetValid},
+		{"wrong audience", cryptoext.RegisteredClaims{Audience: cryptoext.Audience{"web", "admin"}}, cryptoext.ErrJWTInvalidAudience},
+	}
+
+	for _, tc := range tests {
+		token, err := cryptoext.SignJWT(signer, cryptoext.Claims[struct{}]{RegisteredClaims: tc.claims})
+		require.NoError(t, err)
+
+		_, err = cryptoext.ParseJWT[struct{}](verifier, token)
+		if tc.err == nil {
+			require.NoError(t, err, tc.name)
+		} else {
+			require.ErrorIs(t, err, tc.err, tc.name)
+		}
+	}
+}
+
+func Test_Claims_NumericDate(t *testing.T) {
+	t.Parallel()
+
+	var claims cryptoext.Claims[testClaims]
+
+	require.NoError(t, json.Unmarshal([]byte(`{"exp":1700000000.75,"iat":1699999999,"nbf":1.5e9,"role":"admin"}`), &claims))
+	require.Equal(t, int64(1700000000), claims.ExpiresAt)
+	require.Equal(t, int64(1699999999), claims.IssuedAt)
+	require.Equal(t, int64(1500000000), claims.NotBefore)
+	require.Equal(t, "admin", claims.Custom.Role)
+
+	require.Error(t, json.Unmarshal([]byte(`{"exp":"tomorrow"}`), &claims))
+	require.Error(t, json.Unmarshal([]byte(`{"exp":1e30}`), &claims))
+}
+
+func Test_JWKS(t *testing.T) {
+	t.Parallel()
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	key := cryptoext.JWTKey{ID: "es", Algorithm: cryptoext.ES256, Key: ecKey}
+
+	set, err := cryptoext.NewJWKS(key)
+	require.NoError(t, err)
+
+	_, err = cryptoext.NewJWKS(cryptoext.JWTKey{ID: "hs", Algorithm: cryptoext.HS256, Key: []byte("12345678901234567890123456789012")})
+	require.Error(t, err)
+
+	srv := httptest.NewServer(set)
+	t.Cleanup(srv.Close)
+
+	fetched, err := cryptoext.FetchJWKS(context.Background(), srv.Client(), srv.URL)
+	require.NoError(t, err)
+	require.Equal(t, set, fetched)
+
+	fromHandler, err := cryptoext.ReadJWKSFromHandler(set)
+	require.NoError(t, err)
+	require.Equal(t, set, fromHandler)
+
+	_, err = cryptoext.ReadJWKSFromHandler(http.NotFoundHandler())
+	require.ErrorIs(t, err, cryptoext.ErrInvalidJWK)
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	require.NoError(t, json.NewEncoder(f).Encode(set))
+	require.NoError(t, f.Close())
+
+	fromFile, err := cryptoext.ReadJWKSFile(path)
+	require.NoError(t, err)
+
+	verifier, err := cryptoext.NewJWTVerifier(cryptoext.WithJWKS(fromFile))
+	require.NoError(t, err)
+
+	signer, err := cryptoext.NewJWTSigner(key)
+	require.NoError(t, err)
+
+	token, err := cryptoext.SignJWT(signer, cryptoext.Claims[testClaims]{Custom: testClaims{Role: "user"}})
+	require.NoError(t, err)
+
+	claims, err := cryptoext.ParseJWT[testClaims](verifier, token)
+	require.NoError(t, err)
+	require.Equal(t, "user", claims.Custom.Role)
+}
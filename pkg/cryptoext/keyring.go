@@ -0,0 +1,368 @@
+package cryptoext
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+
+	"golang.org/x/crypto/hkdf"
+
+	"github.com/gosom/toolkit/pkg/errorsext"
+)
+
+const (
+	keyringVersion    byte = 1
+	keyringSIVVersion byte = 2
+	keyringHeaderSize      = 5 // version + key id
+	keyringSIVKeyInfo      = "cryptoext/siv/v1"
+	sivKeySize             = 64
+)
+
+var (
+	ErrKeyNotFound        = errors.New("key not found")
+	ErrDuplicateKey       = errors.New("duplicate key id")
+	ErrNoPrimaryKey       = errors.New("keyring has no primary key")
+	ErrPrimaryKeyRemoval  = errors.New("cannot remove the primary key")
+	ErrUnsupportedVersion = errors.New("unsupported ciphertext version")
+)
+
+// Keyring holds multiple versioned encryption keys identified by an id.
+// New data is always encrypted with the primary key, while data encrypted
+// with any key present in the keyring can be decrypted.
+// This allows keys to be rotated gradually: add the new key, make it primary
+// and re-encrypt existing data at your own pace using ReEncrypt.
+//
+// Ciphertexts produced by the Keyring have the following layout:
+//
+//	version (1 byte) | key id (4 bytes, big endian) | nonce | ciphertext
+//
+// The header is authenticated as additional data. Deterministic ciphertexts
+// (see EncryptDeterministic) use the same layout with a different version,
+// with the nonce replaced by the synthetic IV.
+type Keyring struct {
+	mu         sync.RWMutex
+	keys       map[uint32]string
+	primary    uint32
+	hasPrimary bool
+}
+
+// NewKeyring returns an empty keyring.
+func NewKeyring() *Keyring {
+	ans := Keyring{
+		keys: make(map[uint32]string),
+	}
+
+	return &ans
+}
+
+// Add adds a key to the keyring. The key has the same format as the one
+// used by Encrypt. The first key added becomes the primary key.
+func (k *Keyring) Add(id uint32, key string) error {
+	if len(key) != keySize {
+		return errorsext.WithStack(ErrorInvalidKeySize)
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if _, ok := k.keys[id]; ok {
+		return errorsext.WithStack(ErrDuplicateKey)
+	}
+
+	k.keys[id] = key
+
+	if !k.hasPrimary {
+		k.primary = id
+		k.hasPrimary = true
+	}
+
+	return nil
+}
+
+// SetPrimary sets the key that is used for new encryptions.
+func (k *Keyring) SetPrimary(id uint32) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if _, ok := k.keys[id]; !ok {
+		return errorsext.WithStack(ErrKeyNotFound)
+	}
+
+	k.primary = id
+	k.hasPrimary = true
+
+	return nil
+}
+
+// Primary returns the id of the primary key.
+func (k *Keyring) Primary() (uint32, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	if !k.hasPrimary {
+		return 0, errorsext.WithStack(ErrNoPrimaryKey)
+	}
+
+	return k.primary, nil
+}
+
+// Remove removes a retired key from the keyring.
+// Data encrypted with that key can no longer be decrypted.
+func (k *Keyring) Remove(id uint32) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if _, ok := k.keys[id]; !ok {
+		return errorsext.WithStack(ErrKeyNotFound)
+	}
+
+	if k.hasPrimary && k.primary == id {
+		return errorsext.WithStack(ErrPrimaryKeyRemoval)
+	}
+
+	delete(k.keys, id)
+
+	return nil
+}
+
+// Encrypt encrypts data using the primary key.
+func (k *Keyring) Encrypt(data []byte) ([]byte, error) {
+	return k.EncryptWithAD(data, nil)
+}
+
+// Decrypt decrypts data produced by Encrypt using the key it was encrypted with.
+func (k *Keyring) Decrypt(data []byte) ([]byte, error) {
+	return k.DecryptWithAD(data, nil)
+}
+
+// EncryptWithAD encrypts data using the primary key and binds it to
+// additionalData, see the package level EncryptWithAD.
+func (k *Keyring) EncryptWithAD(data, additionalData []byte) ([]byte, error) {
+	id, key, err := k.primaryKey()
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	header := keyringHeader(id)
+
+	return seal(gcm, header, data, append(header, additionalData...))
+}
+
+// DecryptWithAD decrypts data produced by EncryptWithAD.
+func (k *Keyring) DecryptWithAD(data, additionalData []byte) ([]byte, error) {
+	id, err := keyringKeyID(data, keyringVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := k.key(id)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	header := append([]byte{}, data[:keyringHeaderSize]...)
+
+	return open(gcm, data[keyringHeaderSize:], append(header, additionalData...))
+}
+
+// NeedsReEncrypt reports whether data was encrypted with a key other than the primary.
+func (k *Keyring) NeedsReEncrypt(data []byte) (bool, error) {
+	id, err := KeyID(data)
+	if err != nil {
+		return false, err
+	}
+
+	primary, err := k.Primary()
+	if err != nil {
+		return false, err
+	}
+
+	return id != primary, nil
+}
+
+// ReEncrypt decrypts data and encrypts it again with the primary key.
+// If data is already encrypted with the primary key it is returned unchanged.
+func (k *Keyring) ReEncrypt(data []byte) ([]byte, error) {
+	return k.ReEncryptWithAD(data, nil)
+}
+
+// ReEncryptWithAD is like ReEncrypt for data bound to additionalData.
+func (k *Keyring) ReEncryptWithAD(data, additionalData []byte) ([]byte, error) {
+	needed, err := k.NeedsReEncrypt(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if !needed {
+		return data, nil
+	}
+
+	if data[0] == keyringSIVVersion {
+		var ad [][]byte
+		if additionalData != nil {
+			ad = [][]byte{additionalData}
+		}
+
+		plain, err := k.DecryptDeterministic(data, ad...)
+		if err != nil {
+			return nil, err
+		}
+
+		return k.EncryptDeterministic(plain, ad...)
+	}
+
+	plain, err := k.DecryptWithAD(data, additionalData)
+	if err != nil {
+		return nil, err
+	}
+
+	return k.EncryptWithAD(plain, additionalData)
+}
+
+// EncryptDeterministic encrypts data with AES-SIV using a key derived from
+// the primary key. Equal inputs produce equal ciphertexts, so the result can
+// be used for equality lookups; see the package level EncryptDeterministic.
+func (k *Keyring) EncryptDeterministic(data []byte, additionalData ...[]byte) ([]byte, error) {
+	id, key, err := k.primaryKey()
+	if err != nil {
+		return nil, err
+	}
+
+	sivKey, err := deriveKeySize(key, keyringSIVKeyInfo, sivKeySize)
+	if err != nil {
+		return nil, err
+	}
+
+	header := keyringHeader(id)
+	header[0] = keyringSIVVersion
+
+	encrypted, err := EncryptDeterministic(string(sivKey), data, append([][]byte{header}, additionalData...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(header, encrypted...), nil
+}
+
+// DecryptDeterministic decrypts data produced by EncryptDeterministic.
+func (k *Keyring) DecryptDeterministic(data []byte, additionalData ...[]byte) ([]byte, error) {
+	id, err := keyringKeyID(data, keyringSIVVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := k.key(id)
+	if err != nil {
+		return nil, err
+	}
+
+	sivKey, err := deriveKeySize(key, keyringSIVKeyInfo, sivKeySize)
+	if err != nil {
+		return nil, err
+	}
+
+	header := data[:keyringHeaderSize]
+
+	return DecryptDeterministic(string(sivKey), data[keyringHeaderSize:], append([][]byte{header}, additionalData...)...)
+}
+
+// KeyID returns the id of the key that was used to encrypt data.
+func KeyID(data []byte) (uint32, error) {
+	if len(data) > 0 && data[0] == keyringSIVVersion {
+		return keyringKeyID(data, keyringSIVVersion)
+	}
+
+	return keyringKeyID(data, keyringVersion)
+}
+
+func keyringKeyID(data []byte, version byte) (uint32, error) {
+	if len(data) < keyringHeaderSize {
+		return 0, errorsext.WithStack(ErrInvalidCipher)
+	}
+
+	if data[0] != version {
+		return 0, errorsext.WithStack(ErrUnsupportedVersion)
+	}
+
+	return binary.BigEndian.Uint32(data[1:keyringHeaderSize]), nil
+}
+
+func (k *Keyring) primaryKey() (id uint32, key string, err error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	if !k.hasPrimary {
+		return 0, "", errorsext.WithStack(ErrNoPrimaryKey)
+	}
+
+	return k.primary, k.keys[k.primary], nil
+}
+
+func (k *Keyring) key(id uint32) (string, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	key, ok := k.keys[id]
+	if !ok {
+		return "", errorsext.WithStack(ErrKeyNotFound)
+	}
+
+	return key, nil
+}
+
+// derivedPrimaryKey derives a subkey of the primary key for the given purpose,
+// so that keyring keys are never used directly outside AES-GCM.
+func (k *Keyring) derivedPrimaryKey(info string) (uint32, []byte, error) {
+	id, key, err := k.primaryKey()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	derived, err := deriveKey(key, info)
+
+	return id, derived, err
+}
+
+func (k *Keyring) derivedKey(id uint32, info string) ([]byte, error) {
+	key, err := k.key(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return deriveKey(key, info)
+}
+
+func deriveKey(key, info string) ([]byte, error) {
+	return deriveKeySize(key, info, keySize)
+}
+
+func deriveKeySize(key, info string, size int) ([]byte, error) {
+	ans := make([]byte, size)
+
+	kdf := hkdf.New(sha256.New, []byte(key), nil, []byte(info))
+	if _, err := io.ReadFull(kdf, ans); err != nil {
+		return nil, errorsext.WithStack(err)
+	}
+
+	return ans, nil
+}
+
+func keyringHeader(id uint32) []byte {
+	header := make([]byte, keyringHeaderSize)
+	header[0] = keyringVersion
+	binary.BigEndian.PutUint32(header[1:], id)
+
+	return header
+}
@@ -0,0 +1,70 @@
+package cryptoext_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/toolkit/pkg/cryptoext"
+)
+
+func Test_Keyring_Rotation(t *testing.T) {
+	t.Parallel()
+
+	kr := cryptoext.NewKeyring()
+
+	require.NoError(t, kr.Add(1, "12345678901234567890123456789012"))
+
+	old, err := kr.Encrypt([]byte("secret"))
+	require.NoError(t, err)
+
+	id, err := cryptoext.KeyID(old)
+	require.NoError(t, err)
+	require.Equal(t, uint32(1), id)
+
+	require.NoError(t, kr.Add(2, "abcdefghijklmnopqrstuvwxyzabcdef"))
+	require.NoError(t, kr.SetPrimary(2))
+
+	needed, err := kr.NeedsReEncrypt(old)
+	require.NoError(t, err)
+	require.True(t, needed)
+
+	rotated, err := kr.ReEncrypt(old)
+	require.NoError(t, err)
+
+	id, err = cryptoext.KeyID(rotated)
+	require.NoError(t, err)
+	require.Equal(t, uint32(2), id)
+
+	for _, ct := range [][]byte{old, rotated} {
+		plain, err := kr.Decrypt(ct)
+		require.NoError(t, err)
+		require.Equal(t, []byte("secret"), plain)
+	}
+
+	require.ErrorIs(t, kr.Remove(2), cryptoext.ErrPrimaryKeyRemoval)
+	require.NoError(t, kr.Remove(1))
+
+	_, err = kr.Decrypt(old)
+	require.ErrorIs(t, err, cryptoext.ErrKeyNotFound)
+}
+
+func Test_Keyring_TamperedHeader(t *testing.T) {
+	t.Parallel()
+
+	kr := cryptoext.NewKeyring()
+
+	require.NoError(t, kr.Add(1, "12345678901234567890123456789012"))
+	require.NoError(t, kr.Add(2, "12345678901234567890123456789012"))
+
+	ct, err := kr.Encrypt([]byte("secret"))
+	require.NoError(t, err)
+
+	ct[4] = 2
+
+	_, err = kr.Decrypt(ct)
+	require.Error(t, err)
+
+	_, err = kr.Decrypt([]byte{9, 0, 0, 0, 1})
+	require.ErrorIs(t, err, cryptoext.ErrUnsupportedVersion)
+}
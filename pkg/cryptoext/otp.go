@@ -0,0 +1,346 @@
+package cryptoext
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1" //nolint:gosec // HMAC-SHA1 is the default of RFC 4226 and authenticator apps
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gosom/toolkit/pkg/errorsext"
+)
+
+type OTPAlgorithm string
+
+const (
+	OTPSHA1   OTPAlgorithm = "SHA1"
+	OTPSHA256 OTPAlgorithm = "SHA256"
+	OTPSHA512 OTPAlgorithm = "SHA512"
+)
+
+const (
+	DefaultOTPDigits = 6
+	DefaultOTPPeriod = 30 * time.Second
+
+	minOTPDigits = 6
+	maxOTPDigits = 8
+
+	otpSecretSize     = 20
+	recoveryCodeBytes = 10
+	recoveryCodeGroup = 4
+)
+
+var otpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+var (
+	ErrInvalidOTPDigits = errors.New("otp digits must be between 6 and 8")
+	ErrInvalidOTPPeriod = errors.New("otp period must be a positive whole number of seconds")
+)
+
+// GenerateOTPSecret returns a new random 160 bit secret.
+func GenerateOTPSecret() ([]byte, error) {
+	ans := make([]byte, otpSecretSize)
+	if _, err := io.ReadFull(rand.Reader, ans); err != nil {
+		return nil, errorsext.WithStack(err)
+	}
+
+	return ans, nil
+}
+
+// EncodeOTPSecret encodes secret in the base32 form used by authenticator apps.
+func EncodeOTPSecret(secret []byte) string {
+	return otpEncoding.EncodeToString(secret)
+}
+
+// DecodeOTPSecret decodes a base32 secret. Spaces and lowercase letters are accepted.
+func DecodeOTPSecret(s string) ([]byte, error) {
+	s = strings.ToUpper(strings.ReplaceAll(s, " ", ""))
+
+	ans, err := otpEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return nil, errorsext.WithStack(err)
+	}
+
+	return ans, nil
+}
+
+// HOTP generates and validates counter based one time passwords (RFC 4226).
+// Zero values default to 6 digits and SHA1. Use NewHOTP to check settings
+// that come from configuration.
+type HOTP struct {
+	Secret []byte
+	// Digits is clamped to 6-8; NewHOTP rejects other values.
+	Digits    int
+	Algorithm OTPAlgorithm
+	// LookAhead is the number of counters after the expected one that
+	// Validate also accepts, to resynchronize with the client.
+	LookAhead int
+}
+
+// NewHOTP returns a copy of h after checking its settings.
+func NewHOTP(h HOTP) (*HOTP, error) {
+	if err := validateOTPDigits(h.Digits); err != nil {
+		return nil, err
+	}
+
+	return &h, nil
+}
+
+// Generate returns the code for counter.
+func (h *HOTP) Generate(counter uint64) string {
+	return otpCode(h.Secret, counter, h.digits(), h.Algorithm)
+}
+
+// Validate checks code against counter and the following LookAhead counters.
+// On success it returns the counter the caller must store for the next validation.
+func (h *HOTP) Validate(code string, counter uint64) (next uint64, ok bool) {
+	for i := 0; i <= h.LookAhead; i++ {
+		c := counter + uint64(i)
+
+		if otpEqual(code, otpCode(h.Secret, c, h.digits(), h.Algorithm)) {
+			return c + 1, true
+		}
+	}
+
+	return counter, false
+}
+
+// URI returns the otpauth:// enrollment URI for the given counter.
+// It can be rendered as a QR code with qrgen.
+func (h *HOTP) URI(issuer, account string, counter uint64) string {
+	q := otpQuery(h.Secret, issuer, h.Algorithm, h.digits())
+	q.Set("counter", strconv.FormatUint(counter, 10))
+
+	return otpURI("hotp", issuer, account, q)
+}
+
+func (h *HOTP) digits() int {
+	return otpDigits(h.Digits)
+}
+
+// TOTP generates and validates time based one time passwords (RFC 6238).
+// Zero values default to 6 digits, a 30 second period and SHA1. Use NewTOTP
+// to check settings that come from configuration.
+type TOTP struct {
+	Secret []byte
+	// Digits is clamped to 6-8; NewTOTP rejects other values.
+	Digits int
+	// Period is truncated to whole seconds, the unit of authenticator apps;
+	// NewTOTP rejects other values.
+	Period    time.Duration
+	Algorithm OTPAlgorithm
+	// Skew is the number of periods before and after the current one
+	// that Validate also accepts, to tolerate clock drift.
+	Skew int
+}
+
+// NewTOTP returns a copy of t after checking its settings.
+func NewTOTP(t TOTP) (*TOTP, error) {
+	if err := validateOTPDigits(t.Digits); err != nil {
+		return nil, err
+	}
+
+	if t.Period < 0 || t.Period%time.Second != 0 {
+		return nil, errorsext.WithStack(fmt.Errorf("%w: %s", ErrInvalidOTPPeriod, t.Period))
+	}
+
+	return &t, nil
+}
+
+// Generate returns the code for time at.
+func (t *TOTP) Generate(at time.Time) string {
+	return otpCode(t.Secret, t.step(at), t.digits(), t.Algorithm)
+}
+
+// Validate checks code for time at within the drift window.
+// On success it returns the time step that matched; callers should store it
+// and reject codes for the same or earlier steps to prevent replay.
+func (t *TOTP) Validate(code string, at time.Time) (step uint64, ok bool) {
+	current := t.step(at)
+
+	for i := -t.Skew; i <= t.Skew; i++ {
+		if i < 0 && uint64(-i) > current {
+			continue
+		}
+
+		s := uint64(int64(current) + int64(i))
+
+		if otpEqual(code, otpCode(t.Secret, s, t.digits(), t.Algorithm)) {
+			return s, true
+		}
+	}
+
+	return 0, false
+}
+
+// URI returns the otpauth:// enrollment URI.
+// It can be rendered as a QR code with qrgen.GetQRCode.
+func (t *TOTP) URI(issuer, account string) string {
+	q := otpQuery(t.Secret, issuer, t.Algorithm, t.digits())
+	q.Set("period", strconv.Itoa(int(t.period().Seconds())))
+
+	return otpURI("totp", issuer, account, q)
+}
+
+func (t *TOTP) step(at time.Time) uint64 {
+	return uint64(at.Unix() / int64(t.period().Seconds()))
+}
+
+func (t *TOTP) period() time.Duration {
+	if t.Period < time.Second {
+		return DefaultOTPPeriod
+	}
+
+	return t.Period.Truncate(time.Second)
+}
+
+func (t *TOTP) digits() int {
+	return otpDigits(t.Digits)
+}
+
+// GenerateRecoveryCodes returns n single use recovery codes together with
+// their hashes. Only the hashes should be stored; the codes are shown to
+// the user once. Each code carries 80 bits of entropy, so a fast hash is
+// sufficient.
+func GenerateRecoveryCodes(n int) (codes, hashes []string, err error) {
+	codes = make([]string, 0, n)
+	hashes = make([]string, 0, n)
+
+	b := make([]byte, recoveryCodeBytes)
+
+	for i := 0; i < n; i++ {
+		if _, err := io.ReadFull(rand.Reader, b); err != nil {
+			return nil, nil, errorsext.WithStack(err)
+		}
+
+		raw := strings.ToLower(otpEncoding.EncodeToString(b))
+
+		var sb strings.Builder
+
+		for j := 0; j < len(raw); j += recoveryCodeGroup {
+			if j > 0 {
+				sb.WriteByte('-')
+			}
+
+			sb.WriteString(raw[j : j+recoveryCodeGroup])
+		}
+
+		codes = append(codes, sb.String())
+		hashes = append(hashes, Hash([]byte(raw)))
+	}
+
+	return codes, hashes, nil
+}
+
+// VerifyRecoveryCode checks code against the stored hashes and returns the
+// index of the matching hash, which the caller must delete.
+// Case and dashes in code are ignored.
+func VerifyRecoveryCode(code string, hashes []string) (int, bool) {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	h := Hash([]byte(normalized))
+
+	match := -1
+
+	for i := range hashes {
+		if subtle.ConstantTimeCompare([]byte(h), []byte(hashes[i])) == 1 {
+			match = i
+		}
+	}
+
+	return match, match >= 0
+}
+
+// otpDigits returns digits, or the default for zero, clamped to the range
+// otpCode supports.
+func otpDigits(digits int) int {
+	if digits == 0 {
+		return DefaultOTPDigits
+	}
+
+	return min(max(digits, minOTPDigits), maxOTPDigits)
+}
+
+// validateOTPDigits accepts the digits supported by authenticator apps,
+// or zero for the default.
+func validateOTPDigits(digits int) error {
+	if digits != 0 && (digits < minOTPDigits || digits > maxOTPDigits) {
+		return errorsext.WithStack(fmt.Errorf("%w: %d", ErrInvalidOTPDigits, digits))
+	}
+
+	return nil
+}
+
+func otpCode(secret []byte, counter uint64, digits int, algorithm OTPAlgorithm) string {
+	var msg [8]byte
+
+	binary.BigEndian.PutUint64(msg[:], counter)
+
+	mac := hmac.New(otpHash(algorithm), secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint64(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+
+	return fmt.Sprintf("%0*d", digits, uint64(value)%mod)
+}
+
+func otpHash(algorithm OTPAlgorithm) func() hash.Hash {
+	switch algorithm {
+	case OTPSHA256:
+		return sha256.New
+	case OTPSHA512:
+		return sha512.New
+	case OTPSHA1:
+		return sha1.New
+	default:
+		return sha1.New
+	}
+}
+
+func otpEqual(code, expected string) bool {
+	return subtle.ConstantTimeCompare([]byte(strings.TrimSpace(code)), []byte(expected)) == 1
+}
+
+func otpQuery(secret []byte, issuer string, algorithm OTPAlgorithm, digits int) url.Values {
+	if algorithm == "" {
+		algorithm = OTPSHA1
+	}
+
+	q := url.Values{}
+
+	q.Set("secret", EncodeOTPSecret(secret))
+	q.Set("algorithm", string(algorithm))
+	q.Set("digits", strconv.Itoa(digits))
+
+	if issuer != "" {
+		q.Set("issuer", issuer)
+	}
+
+	return q
+}
+
+func otpURI(kind, issuer, account string, q url.Values) string {
+	label := url.PathEscape(account)
+	if issuer != "" {
+		label = url.PathEscape(issuer) + ":" + label
+	}
+
+	return "otpauth://" + kind + "/" + label + "?" + q.Encode()
+}
@@ -0,0 +1,128 @@
+package cryptoext_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/toolkit/pkg/cryptoext"
+)
+
+func Test_HOTP_RFC4226(t *testing.T) {
+	t.Parallel()
+
+	h, err := cryptoext.NewHOTP(cryptoext.HOTP{Secret: []byte("12345678901234567890"), LookAhead: 2})
+	require.NoError(t, err)
+
+	expected := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+
+	for i, code := range expected {
+		require.Equal(t, code, h.Generate(uint64(i)))
+	}
+
+	next, ok := h.Validate("969429", 1)
+	require.True(t, ok)
+	require.Equal(t, uint64(4), next)
+
+	_, ok = h.Validate("520489", 1)
+	require.False(t, ok)
+}
+
+func Test_TOTP_RFC6238(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		algorithm cryptoext.OTPAlgorithm
+		secret    string
+		code      string
+	}{
+		{cryptoext.OTPSHA1, "12345678901234567890", "94287082"},
+		{cryptoext.OTPSHA256, "12345678901234567890123456789012", "46119246"},
+		{cryptoext.OTPSHA512, "1234567890123456789012345678901234567890123456789012345678901234", "90693936"},
+	}
+
+	at := time.Unix(59, 0)
+
+	for _, tc := range tests {
+		totp, err := cryptoext.NewTOTP(cryptoext.TOTP{Secret: []byte(tc.secret), Digits: 8, Algorithm: tc.algorithm, Skew: 1})
+		require.NoError(t, err)
+
+		require.Equal(t, tc.code, totp.Generate(at))
+
+		step, ok := totp.Validate(tc.code, at.Add(30*time.Second))
+		require.True(t, ok)
+		require.Equal(t, uint64(1), step)
+
+		_, ok = totp.Validate(tc.code, at.Add(90*time.Second))
+		require.False(t, ok)
+	}
+}
+
+func Test_OTP_InvalidDigits(t *testing.T) {
+	t.Parallel()
+
+	for _, digits := range []int{-1, 5, 9} {
+		_, err := cryptoext.NewHOTP(cryptoext.HOTP{Secret: []byte("12345678901234567890"), Digits: digits})
+		require.ErrorIs(t, err, cryptoext.ErrInvalidOTPDigits)
+
+		_, err = cryptoext.NewTOTP(cryptoext.TOTP{Secret: []byte("12345678901234567890"), Digits: digits})
+		require.ErrorIs(t, err, cryptoext.ErrInvalidOTPDigits)
+	}
+
+	// struct literals bypass the check, so digits are clamped
+	h := cryptoext.HOTP{Secret: []byte("12345678901234567890"), Digits: 64}
+	require.Len(t, h.Generate(0), 8)
+
+	h.Digits = -3
+	require.Len(t, h.Generate(0), 6)
+}
+
+func Test_TOTP_InvalidPeriod(t *testing.T) {
+	t.Parallel()
+
+	for _, period := range []time.Duration{-time.Second, 1500 * time.Millisecond} {
+		_, err := cryptoext.NewTOTP(cryptoext.TOTP{Secret: []byte("12345678901234567890"), Period: period})
+		require.ErrorIs(t, err, cryptoext.ErrInvalidOTPPeriod)
+	}
+
+	totp, err := cryptoext.NewTOTP(cryptoext.TOTP{Secret: []byte("12345678901234567890"), Period: time.Minute})
+	require.NoError(t, err)
+	require.Contains(t, totp.URI("Toolkit", "john@example.com"), "period=60")
+}
+
+func Test_TOTP_URI(t *testing.T) {
+	t.Parallel()
+
+	secret, err := cryptoext.GenerateOTPSecret()
+	require.NoError(t, err)
+
+	totp := cryptoext.TOTP{Secret: secret}
+
+	uri := totp.URI("Toolkit", "john@example.com")
+	require.True(t, strings.HasPrefix(uri, "otpauth://totp/Toolkit:john@example.com?"))
+	require.Contains(t, uri, "secret="+cryptoext.EncodeOTPSecret(secret))
+	require.Contains(t, uri, "period=30")
+
+	decoded, err := cryptoext.DecodeOTPSecret(strings.ToLower(cryptoext.EncodeOTPSecret(secret)))
+	require.NoError(t, err)
+	require.Equal(t, secret, decoded)
+}
+
+func Test_RecoveryCodes(t *testing.T) {
+	t.Parallel()
+
+	codes, hashes, err := cryptoext.GenerateRecoveryCodes(10)
+	require.NoError(t, err)
+	require.Len(t, codes, 10)
+	require.Len(t, hashes, 10)
+	require.Len(t, codes[0], 19)
+
+	idx, ok := cryptoext.VerifyRecoveryCode(strings.ToUpper(codes[3]), hashes)
+	require.True(t, ok)
+	require.Equal(t, 3, idx)
+
+	_, ok = cryptoext.VerifyRecoveryCode("aaaa-aaaa-aaaa-aaaa", hashes)
+	require.False(t, ok)
+}
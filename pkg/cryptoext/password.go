@@ -0,0 +1,239 @@
+package cryptoext
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/gosom/toolkit/pkg/errorsext"
+)
+
+type PasswordAlgorithm string
+
+const (
+	Argon2id PasswordAlgorithm = "argon2id"
+	Bcrypt   PasswordAlgorithm = "bcrypt"
+)
+
+// DefaultBcryptCost is the bcrypt cost used when none is specified.
+const DefaultBcryptCost = 12
+
+// Argon2Params are the tuning parameters of argon2id.
+// Memory is expressed in KiB.
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2Params follow the OWASP recommendations for argon2id.
+var DefaultArgon2Params = Argon2Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+var (
+	ErrInvalidPasswordHash     = errors.New("invalid password hash")
+	ErrUnsupportedPasswordHash = errors.New("unsupported password hash algorithm")
+	ErrInvalidPasswordParams   = errors.New("invalid password hashing parameters")
+)
+
+var defaultPasswordHasher, _ = NewPasswordHasher()
+
+// HashPassword hashes password with the default PasswordHasher.
+func HashPassword(password string) (string, error) {
+	return defaultPasswordHasher.Hash(password)
+}
+
+// VerifyPassword verifies password with the default PasswordHasher.
+func VerifyPassword(password, encoded string) (match, needsRehash bool, err error) {
+	return defaultPasswordHasher.Verify(password, encoded)
+}
+
+type PasswordOption func(*PasswordHasher) error
+
+// WithArgon2 configures the hasher to use argon2id with the given parameters.
+func WithArgon2(params Argon2Params) PasswordOption {
+	return func(h *PasswordHasher) error {
+		if params.Memory == 0 || params.Iterations == 0 || params.Parallelism == 0 ||
+			params.SaltLength == 0 || params.KeyLength == 0 {
+			return ErrInvalidPasswordParams
+		}
+
+		h.algorithm = Argon2id
+		h.argon2 = params
+
+		return nil
+	}
+}
+
+// WithBcrypt configures the hasher to use bcrypt with the given cost.
+func WithBcrypt(cost int) PasswordOption {
+	return func(h *PasswordHasher) error {
+		if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+			return ErrInvalidPasswordParams
+		}
+
+		h.algorithm = Bcrypt
+		h.bcryptCost = cost
+
+		return nil
+	}
+}
+
+// PasswordHasher hashes passwords for storage.
+// Argon2id hashes are encoded in the PHC string format:
+//
+//	$argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>
+//
+// while bcrypt hashes use the standard $2a$ format.
+//
+// Verify reports whether a stored hash was created with parameters that
+// differ from the current configuration, so it can be upgraded on login.
+type PasswordHasher struct {
+	algorithm  PasswordAlgorithm
+	argon2     Argon2Params
+	bcryptCost int
+}
+
+// NewPasswordHasher creates a PasswordHasher. By default argon2id
+// with DefaultArgon2Params is used.
+func NewPasswordHasher(opts ...PasswordOption) (*PasswordHasher, error) {
+	ans := PasswordHasher{
+		algorithm:  Argon2id,
+		argon2:     DefaultArgon2Params,
+		bcryptCost: DefaultBcryptCost,
+	}
+
+	for _, opt := range opts {
+		if err := opt(&ans); err != nil {
+			return nil, errorsext.WithStack(err)
+		}
+	}
+
+	return &ans, nil
+}
+
+// Hash returns the encoded hash of password.
+func (h *PasswordHasher) Hash(password string) (string, error) {
+	switch h.algorithm {
+	case Argon2id:
+		salt := make([]byte, h.argon2.SaltLength)
+		if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+			return "", errorsext.WithStack(err)
+		}
+
+		key := argon2.IDKey([]byte(password), salt, h.argon2.Iterations, h.argon2.Memory,
+			h.argon2.Parallelism, h.argon2.KeyLength)
+
+		return encodeArgon2(h.argon2, salt, key), nil
+	case Bcrypt:
+		ans, err := bcrypt.GenerateFromPassword([]byte(password), h.bcryptCost)
+		if err != nil {
+			return "", errorsext.WithStack(err)
+		}
+
+		return string(ans), nil
+	default:
+		return "", errorsext.WithStack(ErrUnsupportedPasswordHash)
+	}
+}
+
+// Verify checks password against the encoded hash in constant time.
+// needsRehash is true when the password matches but the hash was created
+// with a different algorithm or parameters than the hasher's configuration.
+// An error is returned only when encoded is malformed.
+func (h *PasswordHasher) Verify(password, encoded string) (match, needsRehash bool, err error) {
+	switch {
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		params, salt, key, err := decodeArgon2(encoded)
+		if err != nil {
+			return false, false, err
+		}
+
+		other := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory,
+			params.Parallelism, uint32(len(key)))
+
+		if subtle.ConstantTimeCompare(key, other) != 1 {
+			return false, false, nil
+		}
+
+		return true, h.algorithm != Argon2id || params != h.argon2, nil
+	case strings.HasPrefix(encoded, "$2"):
+		err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+
+		switch {
+		case errors.Is(err, bcrypt.ErrMismatchedHashAndPassword):
+			return false, false, nil
+		case err != nil:
+			return false, false, errorsext.WithStack(fmt.Errorf("%w: %w", ErrInvalidPasswordHash, err))
+		}
+
+		cost, err := bcrypt.Cost([]byte(encoded))
+		if err != nil {
+			return false, false, errorsext.WithStack(fmt.Errorf("%w: %w", ErrInvalidPasswordHash, err))
+		}
+
+		return true, h.algorithm != Bcrypt || cost != h.bcryptCost, nil
+	default:
+		return false, false, errorsext.WithStack(ErrUnsupportedPasswordHash)
+	}
+}
+
+func encodeArgon2(params Argon2Params, salt, key []byte) string {
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, params.Memory, params.Iterations, params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	)
+}
+
+func decodeArgon2(encoded string) (params Argon2Params, salt, key []byte, err error) {
+	const fields = 6
+
+	parts := strings.Split(encoded, "$")
+	if len(parts) != fields {
+		return params, nil, nil, errorsext.WithStack(ErrInvalidPasswordHash)
+	}
+
+	var version int
+	if _, err = fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return params, nil, nil, errorsext.WithStack(ErrInvalidPasswordHash)
+	}
+
+	if version != argon2.Version {
+		return params, nil, nil, errorsext.WithStack(ErrUnsupportedPasswordHash)
+	}
+
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism)
+	if err != nil || params.Memory == 0 || params.Iterations == 0 || params.Parallelism == 0 {
+		return params, nil, nil, errorsext.WithStack(ErrInvalidPasswordHash)
+	}
+
+	salt, err = base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil || len(salt) == 0 {
+		return params, nil, nil, errorsext.WithStack(ErrInvalidPasswordHash)
+	}
+
+	key, err = base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, errorsext.WithStack(ErrInvalidPasswordHash)
+	}
+
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+
+	return params, salt, key, nil
+}
@@ -0,0 +1,72 @@
+package cryptoext_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/toolkit/pkg/cryptoext"
+)
+
+func Test_PasswordHasher(t *testing.T) {
+	t.Parallel()
+
+	weak := cryptoext.Argon2Params{
+		Memory:      1024,
+		Iterations:  1,
+		Parallelism: 1,
+		SaltLength:  16,
+		KeyLength:   32,
+	}
+
+	h, err := cryptoext.NewPasswordHasher(cryptoext.WithArgon2(weak))
+	require.NoError(t, err)
+
+	encoded, err := h.Hash("hunter2")
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(encoded, "$argon2id$v=19$m=1024,t=1,p=1$"))
+
+	match, rehash, err := h.Verify("hunter2", encoded)
+	require.NoError(t, err)
+	require.True(t, match)
+	require.False(t, rehash)
+
+	match, _, err = h.Verify("hunter3", encoded)
+	require.NoError(t, err)
+	require.False(t, match)
+
+	stronger := weak
+	stronger.Iterations = 2
+
+	h2, err := cryptoext.NewPasswordHasher(cryptoext.WithArgon2(stronger))
+	require.NoError(t, err)
+
+	match, rehash, err = h2.Verify("hunter2", encoded)
+	require.NoError(t, err)
+	require.True(t, match)
+	require.True(t, rehash)
+
+	_, _, err = h.Verify("hunter2", "$argon2id$v=19$garbage")
+	require.ErrorIs(t, err, cryptoext.ErrInvalidPasswordHash)
+}
+
+func Test_PasswordHasher_Bcrypt(t *testing.T) {
+	t.Parallel()
+
+	h, err := cryptoext.NewPasswordHasher(cryptoext.WithBcrypt(4))
+	require.NoError(t, err)
+
+	encoded, err := h.Hash("hunter2")
+	require.NoError(t, err)
+
+	match, rehash, err := h.Verify("hunter2", encoded)
+	require.NoError(t, err)
+	require.True(t, match)
+	require.False(t, rehash)
+
+	match, rehash, err = cryptoext.VerifyPassword("hunter2", encoded)
+	require.NoError(t, err)
+	require.True(t, match)
+	require.True(t, rehash)
+}
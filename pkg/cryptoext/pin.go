@@ -1,26 +1,11 @@
 package cryptoext
 
-import (
-	"crypto/rand"
-	"math/big"
-	"strings"
-
-	"github.com/gosom/toolkit/pkg/errorsext"
-)
-
+// GeneratePIN returns a random PIN of length digits. A length below one
+// returns an empty PIN.
 func GeneratePIN(length int) (string, error) {
-	const numLen = 10
-
-	var sb strings.Builder
-
-	for i := 0; i < length; i++ {
-		num, err := rand.Int(rand.Reader, big.NewInt(numLen))
-		if err != nil {
-			return "", errorsext.WithStack(err)
-		}
-
-		sb.WriteString(num.String())
+	if length < 0 {
+		return "", nil
 	}
 
-	return sb.String(), nil
+	return numericGenerator.Generate(length)
 }
@@ -19,4 +19,8 @@ func Test_GeneratePIN(t *testing.T) {
 	for i := 0; i < len(pin); i++ {
 		require.True(t, pin[i] >= '0' && pin[i] <= '9')
 	}
+
+	pin, err = cryptoext.GeneratePIN(-1)
+	require.NoError(t, err)
+	require.Empty(t, pin)
 }
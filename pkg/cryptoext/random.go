@@ -0,0 +1,149 @@
+package cryptoext
+
+import (
+	"crypto/rand"
+	"errors"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/gosom/toolkit/pkg/errorsext"
+)
+
+const (
+	AlphabetAlphanumeric = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	AlphabetNumeric      = "0123456789"
+	// AlphabetHumanFriendly omits characters that are easily confused
+	// when read or typed, such as 0/O and 1/I/L.
+	AlphabetHumanFriendly = "23456789ABCDEFGHJKMNPQRSTUVWXYZ"
+
+	maxAlphabetSize = 256
+)
+
+var (
+	ErrInvalidAlphabet = errors.New("alphabet must contain between 2 and 256 unique bytes")
+	ErrInvalidLength   = errors.New("length must not be negative")
+)
+
+var (
+	alphanumericGenerator = mustRandomGenerator(AlphabetAlphanumeric)
+	numericGenerator      = mustRandomGenerator(AlphabetNumeric)
+)
+
+type RandomOption func(*RandomGenerator) error
+
+// WithGrouping splits the output in groups of size characters joined by
+// separator, e.g. ABCD-EFGH.
+func WithGrouping(size int, separator string) RandomOption {
+	return func(g *RandomGenerator) error {
+		if size <= 0 {
+			return errors.New("group size must be positive")
+		}
+
+		g.groupSize = size
+		g.separator = separator
+
+		return nil
+	}
+}
+
+// RandomGenerator generates random strings over an alphabet.
+// Random bytes outside the largest multiple of the alphabet size are
+// discarded (rejection sampling), so every character is equally likely.
+type RandomGenerator struct {
+	alphabet  string
+	limit     int
+	groupSize int
+	separator string
+}
+
+// NewRandomGenerator creates a generator for alphabet.
+func NewRandomGenerator(alphabet string, opts ...RandomOption) (*RandomGenerator, error) {
+	if len(alphabet) < 2 || len(alphabet) > maxAlphabetSize {
+		return nil, errorsext.WithStack(ErrInvalidAlphabet)
+	}
+
+	seen := make(map[byte]bool, len(alphabet))
+
+	for i := 0; i < len(alphabet); i++ {
+		if seen[alphabet[i]] {
+			return nil, errorsext.WithStack(ErrInvalidAlphabet)
+		}
+
+		seen[alphabet[i]] = true
+	}
+
+	ans := RandomGenerator{
+		alphabet: alphabet,
+		limit:    maxAlphabetSize - maxAlphabetSize%len(alphabet),
+	}
+
+	for _, opt := range opts {
+		if err := opt(&ans); err != nil {
+			return nil, errorsext.WithStack(err)
+		}
+	}
+
+	return &ans, nil
+}
+
+// Generate returns a string of length characters from the alphabet,
+// not counting group separators.
+func (g *RandomGenerator) Generate(length int) (string, error) {
+	if length < 0 {
+		return "", errorsext.WithStack(ErrInvalidLength)
+	}
+
+	out := make([]byte, 0, length)
+
+	// request some extra bytes up front to account for rejected ones
+	buf := make([]byte, length+length/4+1)
+
+	for len(out) < length {
+		if _, err := io.ReadFull(rand.Reader, buf); err != nil {
+			return "", errorsext.WithStack(err)
+		}
+
+		for _, b := range buf {
+			if int(b) >= g.limit {
+				continue
+			}
+
+			out = append(out, g.alphabet[int(b)%len(g.alphabet)])
+
+			if len(out) == length {
+				break
+			}
+		}
+	}
+
+	if g.groupSize == 0 {
+		return string(out), nil
+	}
+
+	var sb strings.Builder
+
+	for i := 0; i < len(out); i += g.groupSize {
+		if i > 0 {
+			sb.WriteString(g.separator)
+		}
+
+		sb.Write(out[i:min(i+g.groupSize, len(out))])
+	}
+
+	return sb.String(), nil
+}
+
+// EntropyBits returns the entropy of a generated string of length characters.
+func (g *RandomGenerator) EntropyBits(length int) float64 {
+	return float64(length) * math.Log2(float64(len(g.alphabet)))
+}
+
+func mustRandomGenerator(alphabet string) *RandomGenerator {
+	ans, err := NewRandomGenerator(alphabet)
+	if err != nil {
+		panic(err)
+	}
+
+	return ans
+}
@@ -0,0 +1,56 @@
+package cryptoext_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/toolkit/pkg/cryptoext"
+)
+
+func Test_RandomGenerator(t *testing.T) {
+	t.Parallel()
+
+	g, err := cryptoext.NewRandomGenerator(cryptoext.AlphabetHumanFriendly, cryptoext.WithGrouping(4, "-"))
+	require.NoError(t, err)
+
+	code, err := g.Generate(8)
+	require.NoError(t, err)
+	require.Len(t, code, 9)
+	require.Equal(t, byte('-'), code[4])
+
+	for _, c := range strings.ReplaceAll(code, "-", "") {
+		require.True(t, strings.ContainsRune(cryptoext.AlphabetHumanFriendly, c))
+	}
+
+	require.InDelta(t, 8*4.954, g.EntropyBits(8), 0.01)
+
+	_, err = cryptoext.NewRandomGenerator("aa")
+	require.ErrorIs(t, err, cryptoext.ErrInvalidAlphabet)
+}
+
+func Test_RandomGenerator_Uniform(t *testing.T) {
+	t.Parallel()
+
+	// 3 does not divide 256, so a modulo mapping would favour "a"
+	g, err := cryptoext.NewRandomGenerator("abc")
+	require.NoError(t, err)
+
+	const n = 300000
+
+	s, err := g.Generate(n)
+	require.NoError(t, err)
+
+	for _, c := range "abc" {
+		require.InDelta(t, n/3, strings.Count(s, string(c)), n/100)
+	}
+}
+
+func Test_GenerateRandomString(t *testing.T) {
+	t.Parallel()
+
+	s, err := cryptoext.GenerateRandomString(32)
+	require.NoError(t, err)
+	require.Len(t, s, 32)
+}
@@ -0,0 +1,527 @@
+package cryptoext
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+
+	"github.com/gosom/toolkit/pkg/errorsext"
+)
+
+const (
+	ageVersionLine   = "age-encryption.org/v1"
+	ageX25519Label   = "age-encryption.org/v1/X25519"
+	ageRecipientHRP  = "age"
+	ageIdentityHRP   = "age-secret-key-"
+	ageFileKeySize   = 16
+	ageNonceSize     = 16
+	ageColumnsPerRow = 64
+	ageMaxHeaderLine = 1024
+)
+
+var (
+	ErrNoRecipients       = errors.New("no recipients")
+	ErrNoMatchingIdentity = errors.New("no identity matches any of the recipients")
+	ErrInvalidSealHeader  = errors.New("invalid sealed header")
+	ErrInvalidX25519Key   = errors.New("invalid X25519 key")
+)
+
+var ageBase64 = base64.RawStdEncoding.Strict()
+
+// X25519Identity is a private key that can open data sealed to its recipient.
+// Its string form is an age secret key (AGE-SECRET-KEY-1...).
+type X25519Identity struct {
+	key *ecdh.PrivateKey
+}
+
+// X25519Recipient is a public key data can be sealed to.
+// Its string form is an age recipient (age1...).
+type X25519Recipient struct {
+	key *ecdh.PublicKey
+}
+
+// GenerateX25519Identity creates a new random key pair.
+func GenerateX25519Identity() (*X25519Identity, error) {
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, errorsext.WithStack(err)
+	}
+
+	return &X25519Identity{key: key}, nil
+}
+
+// ParseX25519Identity parses an age secret key.
+func ParseX25519Identity(s string) (*X25519Identity, error) {
+	hrp, data, err := bech32Decode(strings.TrimSpace(s))
+	if err != nil || hrp != ageIdentityHRP {
+		return nil, errorsext.WithStack(ErrInvalidX25519Key)
+	}
+
+	key, err := ecdh.X25519().NewPrivateKey(data)
+	if err != nil {
+		return nil, errorsext.WithStack(fmt.Errorf("%w: %w", ErrInvalidX25519Key, err))
+	}
+
+	return &X25519Identity{key: key}, nil
+}
+
+// ParseX25519Recipient parses an age recipient.
+func ParseX25519Recipient(s string) (*X25519Recipient, error) {
+	hrp, data, err := bech32Decode(strings.TrimSpace(s))
+	if err != nil || hrp != ageRecipientHRP {
+		return nil, errorsext.WithStack(ErrInvalidX25519Key)
+	}
+
+	key, err := ecdh.X25519().NewPublicKey(data)
+	if err != nil {
+		return nil, errorsext.WithStack(fmt.Errorf("%w: %w", ErrInvalidX25519Key, err))
+	}
+
+	return &X25519Recipient{key: key}, nil
+}
+
+// ParseX25519IdentityPEM parses a PKCS #8 "PRIVATE KEY" PEM block.
+func ParseX25519IdentityPEM(data []byte) (*X25519Identity, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, errorsext.WithStack(ErrInvalidX25519Key)
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errorsext.WithStack(fmt.Errorf("%w: %w", ErrInvalidX25519Key, err))
+	}
+
+	key, ok := parsed.(*ecdh.PrivateKey)
+	if !ok || key.Curve() != ecdh.X25519() {
+		return nil, errorsext.WithStack(ErrInvalidX25519Key)
+	}
+
+	return &X25519Identity{key: key}, nil
+}
+
+// ParseX25519RecipientPEM parses a PKIX "PUBLIC KEY" PEM block.
+func ParseX25519RecipientPEM(data []byte) (*X25519Recipient, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, errorsext.WithStack(ErrInvalidX25519Key)
+	}
+
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, errorsext.WithStack(fmt.Errorf("%w: %w", ErrInvalidX25519Key, err))
+	}
+
+	key, ok := parsed.(*ecdh.PublicKey)
+	if !ok || key.Curve() != ecdh.X25519() {
+		return nil, errorsext.WithStack(ErrInvalidX25519Key)
+	}
+
+	return &X25519Recipient{key: key}, nil
+}
+
+// Recipient returns the public key of the identity.
+func (i *X25519Identity) Recipient() *X25519Recipient {
+	return &X25519Recipient{key: i.key.PublicKey()}
+}
+
+func (i *X25519Identity) String() string {
+	s, _ := bech32Encode(ageIdentityHRP, i.key.Bytes())
+
+	return strings.ToUpper(s)
+}
+
+// MarshalPEM encodes the identity as a PKCS #8 PEM block.
+func (i *X25519Identity) MarshalPEM() ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(i.key)
+	if err != nil {
+		return nil, errorsext.WithStack(err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+func (r *X25519Recipient) String() string {
+	s, _ := bech32Encode(ageRecipientHRP, r.key.Bytes())
+
+	return s
+}
+
+// MarshalPEM encodes the recipient as a PKIX PEM block.
+func (r *X25519Recipient) MarshalPEM() ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(r.key)
+	if err != nil {
+		return nil, errorsext.WithStack(err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+// Seal encrypts data so that it can only be opened by the identities of
+// recipients. See NewSealWriter.
+func Seal(data []byte, recipients ...*X25519Recipient) ([]byte, error) {
+	var buf bytes.Buffer
+
+	w, err := NewSealWriter(&buf, recipients...)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Unseal decrypts data produced by Seal with one of identities.
+func Unseal(data []byte, identities ...*X25519Identity) ([]byte, error) {
+	r, err := NewUnsealReader(bytes.NewReader(data), identities...)
+	if err != nil {
+		return nil, err
+	}
+
+	ans, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return ans, nil
+}
+
+// NewSealWriter returns a writer that encrypts to recipients in the age v1
+// format (https://age-encryption.org/v1), so the output can also be
+// decrypted with the age command line tool. Close must be called to write
+// the final chunk; it does not close w.
+func NewSealWriter(w io.Writer, recipients ...*X25519Recipient) (io.WriteCloser, error) {
+	if len(recipients) == 0 {
+		return nil, errorsext.WithStack(ErrNoRecipients)
+	}
+
+	fileKey := make([]byte, ageFileKeySize)
+	if _, err := io.ReadFull(rand.Reader, fileKey); err != nil {
+		return nil, errorsext.WithStack(err)
+	}
+
+	var header bytes.Buffer
+
+	header.WriteString(ageVersionLine + "\n")
+
+	for _, r := range recipients {
+		share, body, err := r.wrap(fileKey)
+		if err != nil {
+			return nil, err
+		}
+
+		header.WriteString("-> X25519 " + ageBase64.EncodeToString(share) + "\n")
+		writeAgeBody(&header, body)
+	}
+
+	header.WriteString("---")
+
+	mac, err := ageHeaderMAC(fileKey, header.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	header.WriteString(" " + ageBase64.EncodeToString(mac) + "\n")
+
+	nonce := make([]byte, ageNonceSize)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, errorsext.WithStack(err)
+	}
+
+	header.Write(nonce)
+
+	aead, err := agePayloadAEAD(fileKey, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(header.Bytes()); err != nil {
+		return nil, errorsext.WithStack(err)
+	}
+
+	return newStreamWriter(w, aead), nil
+}
+
+// NewUnsealReader returns a reader that decrypts age v1 data with one of
+// identities. Stanzas for other recipient types are ignored.
+func NewUnsealReader(r io.Reader, identities ...*X25519Identity) (io.Reader, error) {
+	br := bufio.NewReader(r)
+
+	stanzas, headerBytes, mac, err := readAgeHeader(br)
+	if err != nil {
+		return nil, err
+	}
+
+	var fileKey []byte
+
+	for _, s := range stanzas {
+		for _, id := range identities {
+			if fileKey, err = id.unwrap(s); err == nil {
+				break
+			}
+		}
+
+		if fileKey != nil {
+			break
+		}
+	}
+
+	if fileKey == nil {
+		return nil, errorsext.WithStack(ErrNoMatchingIdentity)
+	}
+
+	expected, err := ageHeaderMAC(fileKey, headerBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	if !hmac.Equal(mac, expected) {
+		return nil, errorsext.WithStack(ErrInvalidSealHeader)
+	}
+
+	nonce := make([]byte, ageNonceSize)
+	if _, err := io.ReadFull(br, nonce); err != nil {
+		return nil, errorsext.WithStack(ErrStreamTruncated)
+	}
+
+	aead, err := agePayloadAEAD(fileKey, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return newStreamReader(br, aead), nil
+}
+
+type ageStanza struct {
+	kind string
+	args []string
+	body []byte
+}
+
+func (r *X25519Recipient) wrap(fileKey []byte) (share, body []byte, err error) {
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, errorsext.WithStack(err)
+	}
+
+	shared, err := ephemeral.ECDH(r.key)
+	if err != nil {
+		return nil, nil, errorsext.WithStack(err)
+	}
+
+	share = ephemeral.PublicKey().Bytes()
+
+	aead, err := ageX25519AEAD(shared, share, r.key.Bytes())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	body = aead.Seal(nil, make([]byte, chacha20poly1305.NonceSize), fileKey, nil)
+
+	return share, body, nil
+}
+
+func (i *X25519Identity) unwrap(s ageStanza) ([]byte, error) {
+	if s.kind != "X25519" || len(s.args) != 1 {
+		return nil, errorsext.WithStack(ErrNoMatchingIdentity)
+	}
+
+	share, err := ageBase64.DecodeString(s.args[0])
+	if err != nil {
+		return nil, errorsext.WithStack(ErrInvalidSealHeader)
+	}
+
+	pub, err := ecdh.X25519().NewPublicKey(share)
+	if err != nil {
+		return nil, errorsext.WithStack(ErrInvalidSealHeader)
+	}
+
+	shared, err := i.key.ECDH(pub)
+	if err != nil {
+		return nil, errorsext.WithStack(ErrInvalidSealHeader)
+	}
+
+	aead, err := ageX25519AEAD(shared, share, i.key.PublicKey().Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	fileKey, err := aead.Open(nil, make([]byte, chacha20poly1305.NonceSize), s.body, nil)
+	if err != nil {
+		return nil, errorsext.WithStack(ErrNoMatchingIdentity)
+	}
+
+	if len(fileKey) != ageFileKeySize {
+		return nil, errorsext.WithStack(ErrInvalidSealHeader)
+	}
+
+	return fileKey, nil
+}
+
+func ageX25519AEAD(shared, share, recipient []byte) (cipher.AEAD, error) {
+	salt := make([]byte, 0, len(share)+len(recipient))
+	salt = append(salt, share...)
+	salt = append(salt, recipient...)
+
+	key, err := ageHKDF(shared, salt, ageX25519Label)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, errorsext.WithStack(err)
+	}
+
+	return aead, nil
+}
+
+func agePayloadAEAD(fileKey, nonce []byte) (cipher.AEAD, error) {
+	key, err := ageHKDF(fileKey, nonce, "payload")
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, errorsext.WithStack(err)
+	}
+
+	return aead, nil
+}
+
+func ageHeaderMAC(fileKey, header []byte) ([]byte, error) {
+	key, err := ageHKDF(fileKey, nil, "header")
+	if err != nil {
+		return nil, err
+	}
+
+	h := hmac.New(sha256.New, key)
+	h.Write(header)
+
+	return h.Sum(nil), nil
+}
+
+func ageHKDF(secret, salt []byte, info string) ([]byte, error) {
+	ans := make([]byte, chacha20poly1305.KeySize)
+
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, []byte(info)), ans); err != nil {
+		return nil, errorsext.WithStack(err)
+	}
+
+	return ans, nil
+}
+
+// writeAgeBody writes body base64 encoded in lines of 64 columns.
+// The last line is always shorter than 64 columns, and may be empty.
+func writeAgeBody(w *bytes.Buffer, body []byte) {
+	encoded := ageBase64.EncodeToString(body)
+
+	for len(encoded) >= ageColumnsPerRow {
+		w.WriteString(encoded[:ageColumnsPerRow] + "\n")
+		encoded = encoded[ageColumnsPerRow:]
+	}
+
+	w.WriteString(encoded + "\n")
+}
+
+// readAgeHeader parses the header and returns the stanzas, the bytes the
+// MAC is computed over and the MAC itself.
+func readAgeHeader(r *bufio.Reader) (stanzas []ageStanza, header, mac []byte, err error) {
+	var raw bytes.Buffer
+
+	line, err := readAgeLine(r, &raw)
+	if err != nil || line != ageVersionLine {
+		return nil, nil, nil, errorsext.WithStack(ErrInvalidSealHeader)
+	}
+
+	for {
+		line, err = readAgeLine(r, &raw)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		if strings.HasPrefix(line, "--- ") {
+			mac, err = ageBase64.DecodeString(line[4:])
+			if err != nil {
+				return nil, nil, nil, errorsext.WithStack(ErrInvalidSealHeader)
+			}
+
+			// the MAC covers the header up to and including "---"
+			header = raw.Bytes()[:raw.Len()-len(line)-1+3]
+
+			return stanzas, header, mac, nil
+		}
+
+		fields := strings.Split(line, " ")
+		if len(fields) < 2 || fields[0] != "->" {
+			return nil, nil, nil, errorsext.WithStack(ErrInvalidSealHeader)
+		}
+
+		s := ageStanza{kind: fields[1], args: fields[2:]}
+
+		for {
+			line, err = readAgeLine(r, &raw)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+
+			chunk, err := ageBase64.DecodeString(line)
+			if err != nil || len(line) > ageColumnsPerRow {
+				return nil, nil, nil, errorsext.WithStack(ErrInvalidSealHeader)
+			}
+
+			s.body = append(s.body, chunk...)
+
+			if len(line) < ageColumnsPerRow {
+				break
+			}
+		}
+
+		stanzas = append(stanzas, s)
+	}
+}
+
+func readAgeLine(r *bufio.Reader, raw *bytes.Buffer) (string, error) {
+	var line []byte
+
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return "", errorsext.WithStack(ErrInvalidSealHeader)
+		}
+
+		raw.WriteByte(b)
+
+		if b == '\n' {
+			return string(line), nil
+		}
+
+		line = append(line, b)
+
+		if len(line) > ageMaxHeaderLine {
+			return "", errorsext.WithStack(ErrInvalidSealHeader)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package cryptoext_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/toolkit/pkg/cryptoext"
+)
+
+func Test_Seal(t *testing.T) {
+	t.Parallel()
+
+	alice, err := cryptoext.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	bob, err := cryptoext.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	eve, err := cryptoext.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	for _, size := range []int{0, 100, 64 * 1024, 200 * 1024} {
+		plain := bytes.Repeat([]byte("x"), size)
+
+		sealed, err := cryptoext.Seal(plain, alice.Recipient(), bob.Recipient())
+		require.NoError(t, err)
+		require.True(t, bytes.HasPrefix(sealed, []byte("age-encryption.org/v1\n-> X25519 ")))
+
+		opened, err := cryptoext.Unseal(sealed, bob)
+		require.NoError(t, err)
+		require.Equal(t, plain, opened)
+
+		_, err = cryptoext.Unseal(sealed, eve)
+		require.ErrorIs(t, err, cryptoext.ErrNoMatchingIdentity)
+	}
+}
+
+// ageExample is testdata/example.age of filippo.io/age v1.1.1, encrypted
+// by age to the recipient of ageExampleIdentity.
+const (
+	ageExample         = "YWdlLWVuY3J5cHRpb24ub3JnL3YxCi0+IFgyNTUxOSA4aHJsTStaQkczRGQ0ZkYyK2E1ODN6ZFRJV0RrOC9SNDFrQ1lac3Z3VFc0CnlPNFBZZGxNV0RKK0N4Z1VOUnFZNVowVC9tK2czRkNoNWpJeEdMYkNWWGMKLS0tIEkvaW1ldlp6eTgxMjBKU3ptSm5tbi9LTWszcDVBMTFWODNOazQxbTlOUEUKcMXlNiShUgdT+Sxa0Q7KsnO6TWEXgHcT6DggQXod8soIGCJyyPhchXc0oTEaO3XpjQ6v"
+	ageExampleIdentity = "AGE-SECRET-KEY-184JMZMVQH3E6U0PSL869004Y3U2NYV7R30EU99CSEDNPH02YUVFSZW44VU"
+)
+
+func Test_Seal_AgeInterop(t *testing.T) {
+	t.Parallel()
+
+	sealed, err := base64.StdEncoding.DecodeString(ageExample)
+	require.NoError(t, err)
+
+	id, err := cryptoext.ParseX25519Identity(ageExampleIdentity)
+	require.NoError(t, err)
+
+	opened, err := cryptoext.Unseal(sealed, id)
+	require.NoError(t, err)
+	require.Equal(t, "Black lives matter.", string(opened))
+
+	// the output has the header layout of age: one X25519 stanza per
+	// recipient with a 32 byte share and a 32 byte body, the MAC and a
+	// 16 byte nonce
+	sealed, err = cryptoext.Seal([]byte("Black lives matter."), id.Recipient())
+	require.NoError(t, err)
+
+	header := regexp.MustCompile(`^age-encryption\.org/v1\n-> X25519 [A-Za-z0-9+/]{43}\n[A-Za-z0-9+/]{43}\n--- [A-Za-z0-9+/]{43}\n`)
+
+	loc := header.FindIndex(sealed)
+	require.NotNil(t, loc)
+
+	// nonce, then the payload with its 16 byte tag
+	require.Len(t, sealed[loc[1]:], 16+len("Black lives matter.")+16)
+
+	opened, err = cryptoext.Unseal(sealed, id)
+	require.NoError(t, err)
+	require.Equal(t, "Black lives matter.", string(opened))
+}
+
+func Test_X25519_Encoding(t *testing.T) {
+	t.Parallel()
+
+	id, err := cryptoext.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	require.True(t, strings.HasPrefix(id.String(), "AGE-SECRET-KEY-1"))
+	require.True(t, strings.HasPrefix(id.Recipient().String(), "age1"))
+
+	parsed, err := cryptoext.ParseX25519Identity(id.String())
+	require.NoError(t, err)
+	require.Equal(t, id.String(), parsed.String())
+
+	recipient, err := cryptoext.ParseX25519Recipient(id.Recipient().String())
+	require.NoError(t, err)
+	require.Equal(t, id.Recipient().String(), recipient.String())
+
+	privPEM, err := id.MarshalPEM()
+	require.NoError(t, err)
+
+	fromPEM, err := cryptoext.ParseX25519IdentityPEM(privPEM)
+	require.NoError(t, err)
+	require.Equal(t, id.String(), fromPEM.String())
+
+	pubPEM, err := id.Recipient().MarshalPEM()
+	require.NoError(t, err)
+
+	pubFromPEM, err := cryptoext.ParseX25519RecipientPEM(pubPEM)
+	require.NoError(t, err)
+	require.Equal(t, recipient.String(), pubFromPEM.String())
+
+	_, err = cryptoext.ParseX25519Recipient("age1" + strings.Repeat("q", 58))
+	require.ErrorIs(t, err, cryptoext.ErrInvalidX25519Key)
+}
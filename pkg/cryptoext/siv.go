@@ -0,0 +1,188 @@
+package cryptoext
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"errors"
+
+	"github.com/gosom/toolkit/pkg/errorsext"
+)
+
+const sivBlockSize = aes.BlockSize
+
+var ErrInvalidSIVKeySize = errors.New("invalid key size, AES-SIV requires 32, 48 or 64 bytes")
+
+// EncryptDeterministic encrypts data with AES-SIV (RFC 5297).
+// The same key, data and additional data always produce the same ciphertext,
+// which allows equality lookups on encrypted values at the cost of revealing
+// which values are equal. Use Encrypt unless that is required.
+//
+// The key is split in two halves, so 32, 48 and 64 byte keys select
+// AES-128, AES-192 and AES-256 respectively.
+func EncryptDeterministic(key string, data []byte, additionalData ...[]byte) ([]byte, error) {
+	macKey, ctrKey, err := newSIVCiphers(key)
+	if err != nil {
+		return nil, err
+	}
+
+	v := s2v(macKey, data, additionalData)
+
+	ans := make([]byte, sivBlockSize+len(data))
+	copy(ans, v[:])
+
+	sivCTR(ctrKey, v, ans[sivBlockSize:], data)
+
+	return ans, nil
+}
+
+// DecryptDeterministic decrypts data produced by EncryptDeterministic.
+func DecryptDeterministic(key string, data []byte, additionalData ...[]byte) ([]byte, error) {
+	macKey, ctrKey, err := newSIVCiphers(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < sivBlockSize {
+		return nil, errorsext.WithStack(ErrInvalidCipher)
+	}
+
+	var v [sivBlockSize]byte
+
+	copy(v[:], data[:sivBlockSize])
+
+	ans := make([]byte, len(data)-sivBlockSize)
+
+	sivCTR(ctrKey, v, ans, data[sivBlockSize:])
+
+	expected := s2v(macKey, ans, additionalData)
+	if subtle.ConstantTimeCompare(v[:], expected[:]) != 1 {
+		return nil, errorsext.WithStack(ErrInvalidCipher)
+	}
+
+	return ans, nil
+}
+
+func newSIVCiphers(key string) (macKey, ctrKey cipher.Block, err error) {
+	switch len(key) {
+	case 32, 48, 64:
+	default:
+		return nil, nil, errorsext.WithStack(ErrInvalidSIVKeySize)
+	}
+
+	half := len(key) / 2
+
+	macKey, err = aes.NewCipher([]byte(key[:half]))
+	if err != nil {
+		return nil, nil, errorsext.WithStack(err)
+	}
+
+	ctrKey, err = aes.NewCipher([]byte(key[half:]))
+	if err != nil {
+		return nil, nil, errorsext.WithStack(err)
+	}
+
+	return macKey, ctrKey, nil
+}
+
+func sivCTR(block cipher.Block, v [sivBlockSize]byte, dst, src []byte) {
+	// clear the 31st and 63rd bits so that implementations can use
+	// 32 and 64 bit counters (RFC 5297 section 2.5).
+	v[8] &= 0x7f
+	v[12] &= 0x7f
+
+	cipher.NewCTR(block, v[:]).XORKeyStream(dst, src)
+}
+
+// s2v implements the S2V construction of RFC 5297 with the additional
+// data strings followed by the plaintext.
+func s2v(block cipher.Block, plaintext []byte, additionalData [][]byte) [sivBlockSize]byte {
+	var zero [sivBlockSize]byte
+
+	d := cmac(block, zero[:])
+
+	for _, ad := range additionalData {
+		d = dbl(d)
+		mac := cmac(block, ad)
+		subtle.XORBytes(d[:], d[:], mac[:])
+	}
+
+	var t []byte
+
+	if len(plaintext) >= sivBlockSize {
+		t = make([]byte, len(plaintext))
+		copy(t, plaintext)
+
+		tail := t[len(t)-sivBlockSize:]
+		subtle.XORBytes(tail, tail, d[:])
+	} else {
+		d = dbl(d)
+
+		var padded [sivBlockSize]byte
+
+		copy(padded[:], plaintext)
+		padded[len(plaintext)] = 0x80
+
+		subtle.XORBytes(d[:], d[:], padded[:])
+
+		t = d[:]
+	}
+
+	return cmac(block, t)
+}
+
+// cmac implements AES-CMAC (RFC 4493).
+func cmac(block cipher.Block, msg []byte) [sivBlockSize]byte {
+	var l [sivBlockSize]byte
+
+	block.Encrypt(l[:], l[:])
+
+	k1 := dbl(l)
+	k2 := dbl(k1)
+
+	n := (len(msg) + sivBlockSize - 1) / sivBlockSize
+	complete := n > 0 && len(msg)%sivBlockSize == 0
+
+	if n == 0 {
+		n = 1
+	}
+
+	var last [sivBlockSize]byte
+
+	rest := msg[(n-1)*sivBlockSize:]
+	copy(last[:], rest)
+
+	if complete {
+		subtle.XORBytes(last[:], last[:], k1[:])
+	} else {
+		last[len(rest)] = 0x80
+		subtle.XORBytes(last[:], last[:], k2[:])
+	}
+
+	var x [sivBlockSize]byte
+
+	for i := 0; i < n-1; i++ {
+		subtle.XORBytes(x[:], x[:], msg[i*sivBlockSize:(i+1)*sivBlockSize])
+		block.Encrypt(x[:], x[:])
+	}
+
+	subtle.XORBytes(x[:], x[:], last[:])
+	block.Encrypt(x[:], x[:])
+
+	return x
+}
+
+// dbl multiplies by x in GF(2^128).
+func dbl(b [sivBlockSize]byte) [sivBlockSize]byte {
+	var ans [sivBlockSize]byte
+
+	carry := b[0] >> 7
+
+	for i := 0; i < sivBlockSize-1; i++ {
+		ans[i] = b[i]<<1 | b[i+1]>>7
+	}
+
+	ans[sivBlockSize-1] = b[sivBlockSize-1]<<1 ^ (carry * 0x87)
+
+	return ans
+}
@@ -0,0 +1,79 @@
+package cryptoext_test
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/toolkit/pkg/cryptoext"
+)
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+
+	ans, err := hex.DecodeString(s)
+	require.NoError(t, err)
+
+	return ans
+}
+
+func Test_EncryptDeterministic_RFC5297(t *testing.T) {
+	t.Parallel()
+
+	key := string(mustHex(t, "fffefdfcfbfaf9f8f7f6f5f4f3f2f1f0f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff"))
+	ad := mustHex(t, "101112131415161718191a1b1c1d1e1f2021222324252627")
+	plain := mustHex(t, "112233445566778899aabbccddee")
+
+	encrypted, err := cryptoext.EncryptDeterministic(key, plain, ad)
+	require.NoError(t, err)
+	require.Equal(t, "85632d07c6e8f37f950acd320a2ecc9340c02b9690c4dc04daef7f6afe5c", hex.EncodeToString(encrypted))
+
+	decrypted, err := cryptoext.DecryptDeterministic(key, encrypted, ad)
+	require.NoError(t, err)
+	require.Equal(t, plain, decrypted)
+
+	_, err = cryptoext.DecryptDeterministic(key, encrypted)
+	require.ErrorIs(t, err, cryptoext.ErrInvalidCipher)
+}
+
+func Test_Keyring_Deterministic(t *testing.T) {
+	t.Parallel()
+
+	kr := cryptoext.NewKeyring()
+	require.NoError(t, kr.Add(1, "12345678901234567890123456789012"))
+
+	a, err := kr.EncryptDeterministic([]byte("john@example.com"))
+	require.NoError(t, err)
+
+	b, err := kr.EncryptDeterministic([]byte("john@example.com"))
+	require.NoError(t, err)
+	require.Equal(t, a, b)
+
+	plain, err := kr.DecryptDeterministic(a)
+	require.NoError(t, err)
+	require.Equal(t, []byte("john@example.com"), plain)
+
+	idx, err := kr.BlindIndex("users.email", []byte("john@example.com"), 8)
+	require.NoError(t, err)
+	require.Len(t, idx, 16)
+
+	other, err := kr.BlindIndex("users.phone", []byte("john@example.com"), 8)
+	require.NoError(t, err)
+	require.NotEqual(t, idx, other)
+
+	require.NoError(t, kr.Add(2, "abcdefghijklmnopqrstuvwxyzabcdef"))
+	require.NoError(t, kr.SetPrimary(2))
+
+	all, err := kr.BlindIndexes("users.email", []byte("john@example.com"), 8)
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+	require.Equal(t, idx, all[1])
+
+	rotated, err := kr.ReEncrypt(a)
+	require.NoError(t, err)
+
+	plain, err = kr.DecryptDeterministic(rotated)
+	require.NoError(t, err)
+	require.Equal(t, []byte("john@example.com"), plain)
+}
@@ -0,0 +1,279 @@
+package cryptoext
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+
+	"github.com/gosom/toolkit/pkg/errorsext"
+)
+
+const (
+	streamVersion    byte = 1
+	streamSaltSize        = 16
+	streamChunkSize       = 64 * 1024
+	streamNonceSize       = 12
+	streamLastChunk  byte = 1
+	streamKeyInfo         = "cryptoext/stream/v1"
+	streamMaxCounter      = 1<<64 - 1
+)
+
+var (
+	ErrStreamTruncated = errors.New("stream is truncated")
+	ErrStreamClosed    = errors.New("stream is closed")
+	ErrStreamTooLarge  = errors.New("stream is too large")
+)
+
+// NewEncryptWriter returns a writer that encrypts everything written to it
+// and writes the ciphertext to w. The key has the same format as the one
+// used by Encrypt.
+//
+// The plaintext is split in chunks of 64KiB that are sealed individually
+// with AES-GCM, so arbitrarily large inputs can be encrypted with constant
+// memory. A per-stream key is derived from key and a random salt, and each
+// chunk nonce is derived from the chunk index plus a flag that marks the
+// final chunk, which protects against reordering and truncation.
+//
+// Close must be called to write the final chunk. It does not close w.
+func NewEncryptWriter(w io.Writer, key string) (io.WriteCloser, error) {
+	salt := make([]byte, streamSaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, errorsext.WithStack(err)
+	}
+
+	aead, err := newStreamAEAD(key, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	header := append([]byte{streamVersion}, salt...)
+	if _, err := w.Write(header); err != nil {
+		return nil, errorsext.WithStack(err)
+	}
+
+	return newStreamWriter(w, aead), nil
+}
+
+// NewDecryptReader returns a reader that decrypts data produced by NewEncryptWriter.
+// Read returns ErrStreamTruncated if r ends before the final chunk and
+// ErrInvalidCipher if a chunk fails authentication. Data returned before
+// an error has been authenticated, but callers must not treat the result
+// as complete until Read returns io.EOF.
+func NewDecryptReader(r io.Reader, key string) (io.Reader, error) {
+	header := make([]byte, 1+streamSaltSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, errorsext.WithStack(ErrStreamTruncated)
+	}
+
+	if header[0] != streamVersion {
+		return nil, errorsext.WithStack(ErrUnsupportedVersion)
+	}
+
+	aead, err := newStreamAEAD(key, header[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	return newStreamReader(r, aead), nil
+}
+
+func newStreamAEAD(key string, salt []byte) (cipher.AEAD, error) {
+	if len(key) != keySize {
+		return nil, errorsext.WithStack(ErrorInvalidKeySize)
+	}
+
+	streamKey := make([]byte, keySize)
+
+	kdf := hkdf.New(sha256.New, []byte(key), salt, []byte(streamKeyInfo))
+	if _, err := io.ReadFull(kdf, streamKey); err != nil {
+		return nil, errorsext.WithStack(err)
+	}
+
+	return newGCM(string(streamKey))
+}
+
+// streamWriter implements the STREAM construction on top of any AEAD with
+// a 12 byte nonce. The nonce is an 11 byte big endian chunk counter
+// followed by a byte that is 1 for the final chunk and 0 otherwise.
+type streamWriter struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	buf     []byte
+	out     []byte
+	counter uint64
+	closed  bool
+}
+
+func newStreamWriter(w io.Writer, aead cipher.AEAD) *streamWriter {
+	ans := streamWriter{
+		w:    w,
+		aead: aead,
+		buf:  make([]byte, 0, streamChunkSize),
+		out:  make([]byte, 0, streamChunkSize+aead.Overhead()),
+	}
+
+	return &ans
+}
+
+func (s *streamWriter) Write(p []byte) (int, error) {
+	if s.closed {
+		return 0, errorsext.WithStack(ErrStreamClosed)
+	}
+
+	total := 0
+
+	for len(p) > 0 {
+		// a full chunk is flushed only when more data arrives,
+		// so that the final chunk is always marked as such on Close.
+		if len(s.buf) == streamChunkSize {
+			if err := s.flush(false); err != nil {
+				return total, err
+			}
+		}
+
+		n := copy(s.buf[len(s.buf):streamChunkSize], p)
+		s.buf = s.buf[:len(s.buf)+n]
+		p = p[n:]
+		total += n
+	}
+
+	return total, nil
+}
+
+func (s *streamWriter) Close() error {
+	if s.closed {
+		return nil
+	}
+
+	s.closed = true
+
+	return s.flush(true)
+}
+
+func (s *streamWriter) flush(last bool) error {
+	if s.counter == streamMaxCounter {
+		return errorsext.WithStack(ErrStreamTooLarge)
+	}
+
+	nonce := streamNonce(s.counter, last)
+
+	s.out = s.aead.Seal(s.out[:0], nonce[:], s.buf, nil)
+	s.buf = s.buf[:0]
+	s.counter++
+
+	if _, err := s.w.Write(s.out); err != nil {
+		return errorsext.WithStack(err)
+	}
+
+	return nil
+}
+
+type streamReader struct {
+	r       *bufio.Reader
+	aead    cipher.AEAD
+	buf     []byte
+	out     []byte
+	plain   []byte
+	counter uint64
+	done    bool
+	err     error
+}
+
+func newStreamReader(r io.Reader, aead cipher.AEAD) *streamReader {
+	ans := streamReader{
+		r:    bufio.NewReader(r),
+		aead: aead,
+		buf:  make([]byte, streamChunkSize+aead.Overhead()),
+		out:  make([]byte, 0, streamChunkSize),
+	}
+
+	return &ans
+}
+
+func (s *streamReader) Read(p []byte) (int, error) {
+	for len(s.plain) == 0 {
+		if s.err != nil {
+			return 0, s.err
+		}
+
+		if s.done {
+			return 0, io.EOF
+		}
+
+		s.err = s.readChunk()
+	}
+
+	n := copy(p, s.plain)
+	s.plain = s.plain[n:]
+
+	return n, nil
+}
+
+func (s *streamReader) readChunk() error {
+	n, err := io.ReadFull(s.r, s.buf)
+
+	var last bool
+
+	switch {
+	case errors.Is(err, io.EOF):
+		return errorsext.WithStack(ErrStreamTruncated)
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		last = true
+	case err != nil:
+		return errorsext.WithStack(err)
+	default:
+		if _, err := s.r.Peek(1); errors.Is(err, io.EOF) {
+			last = true
+		} else if err != nil {
+			return errorsext.WithStack(err)
+		}
+	}
+
+	if n < s.aead.Overhead() {
+		return errorsext.WithStack(ErrStreamTruncated)
+	}
+
+	nonce := streamNonce(s.counter, last)
+
+	plain, err := s.aead.Open(s.out[:0], nonce[:], s.buf[:n], nil)
+	if err != nil {
+		if last {
+			// a chunk that authenticates as non final means the stream
+			// was cut at a chunk boundary.
+			nonce = streamNonce(s.counter, false)
+			if _, err := s.aead.Open(s.out[:0], nonce[:], s.buf[:n], nil); err == nil {
+				return errorsext.WithStack(ErrStreamTruncated)
+			}
+		}
+
+		return errorsext.WithStack(ErrInvalidCipher)
+	}
+
+	if last && len(plain) == 0 && s.counter > 0 {
+		return errorsext.WithStack(ErrInvalidCipher)
+	}
+
+	s.plain = plain
+	s.counter++
+	s.done = last
+
+	return nil
+}
+
+func streamNonce(counter uint64, last bool) [streamNonceSize]byte {
+	var nonce [streamNonceSize]byte
+
+	binary.BigEndian.PutUint64(nonce[3:11], counter)
+
+	if last {
+		nonce[11] = streamLastChunk
+	}
+
+	return nonce
+}
@@ -0,0 +1,75 @@
+package cryptoext_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/toolkit/pkg/cryptoext"
+)
+
+func Test_Stream_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	const key = "12345678901234567890123456789012"
+
+	for _, size := range []int{0, 1, 64 * 1024, 64*1024 + 1, 3*64*1024 + 17} {
+		plain := make([]byte, size)
+		_, err := rand.Read(plain)
+		require.NoError(t, err)
+
+		var encrypted bytes.Buffer
+
+		w, err := cryptoext.NewEncryptWriter(&encrypted, key)
+		require.NoError(t, err)
+
+		_, err = io.Copy(w, bytes.NewReader(plain))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+
+		r, err := cryptoext.NewDecryptReader(bytes.NewReader(encrypted.Bytes()), key)
+		require.NoError(t, err)
+
+		decrypted, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.Equal(t, plain, decrypted)
+	}
+}
+
+func Test_Stream_Truncated(t *testing.T) {
+	t.Parallel()
+
+	const key = "12345678901234567890123456789012"
+
+	plain := make([]byte, 2*64*1024+10)
+
+	var encrypted bytes.Buffer
+
+	w, err := cryptoext.NewEncryptWriter(&encrypted, key)
+	require.NoError(t, err)
+
+	_, err = w.Write(plain)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	// header + first chunk only
+	cut := encrypted.Bytes()[:17+64*1024+16]
+
+	r, err := cryptoext.NewDecryptReader(bytes.NewReader(cut), key)
+	require.NoError(t, err)
+
+	_, err = io.ReadAll(r)
+	require.ErrorIs(t, err, cryptoext.ErrStreamTruncated)
+
+	tampered := bytes.Clone(encrypted.Bytes())
+	tampered[len(tampered)-1] ^= 1
+
+	r, err = cryptoext.NewDecryptReader(bytes.NewReader(tampered), key)
+	require.NoError(t, err)
+
+	_, err = io.ReadAll(r)
+	require.ErrorIs(t, err, cryptoext.ErrInvalidCipher)
+}
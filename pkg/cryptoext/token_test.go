@@ -0,0 +1,70 @@
+package cryptoext_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/toolkit/pkg/cryptoext"
+)
+
+func Test_TokenSigner(t *testing.T) {
+	t.Parallel()
+
+	kr := cryptoext.NewKeyring()
+	require.NoError(t, kr.Add(1, "12345678901234567890123456789012"))
+
+	signer := cryptoext.NewTokenSigner(kr)
+
+	token, err := signer.Sign("password-reset", []byte("user:42"), time.Now().Add(time.Hour))
+	require.NoError(t, err)
+
+	data, err := signer.Verify("password-reset", token)
+	require.NoError(t, err)
+	require.Equal(t, []byte("user:42"), data)
+
+	_, err = signer.Verify("email-verification", token)
+	require.ErrorIs(t, err, cryptoext.ErrTokenInvalid)
+
+	forBilling, err := signer.SignFor("billing", "download:invoice", []byte("invoice:7"), time.Time{})
+	require.NoError(t, err)
+
+	data, err = signer.VerifyFor("billing", "download:invoice", forBilling)
+	require.NoError(t, err)
+	require.Equal(t, []byte("invoice:7"), data)
+
+	_, err = signer.VerifyFor("reports", "download:invoice", forBilling)
+	require.ErrorIs(t, err, cryptoext.ErrTokenAudience)
+
+	_, err = signer.Verify("download:invoice", forBilling)
+	require.ErrorIs(t, err, cryptoext.ErrTokenAudience)
+
+	_, err = signer.VerifyFor("billing", "password-reset", token)
+	require.ErrorIs(t, err, cryptoext.ErrTokenAudience)
+
+	tampered := []byte(token)
+	tampered[len(tampered)/2] ^= 1
+
+	_, err = signer.Verify("password-reset", string(tampered))
+	require.ErrorIs(t, err, cryptoext.ErrTokenInvalid)
+
+	expired, err := signer.Sign("password-reset", []byte("user:42"), time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+
+	_, err = signer.Verify("password-reset", expired)
+	require.ErrorIs(t, err, cryptoext.ErrTokenExpired)
+
+	// tokens signed with a retired key stay valid while the key is in the keyring
+	require.NoError(t, kr.Add(2, "abcdefghijklmnopqrstuvwxyzabcdef"))
+	require.NoError(t, kr.SetPrimary(2))
+
+	data, err = signer.Verify("password-reset", token)
+	require.NoError(t, err)
+	require.Equal(t, []byte("user:42"), data)
+
+	require.NoError(t, kr.Remove(1))
+
+	_, err = signer.Verify("password-reset", token)
+	require.ErrorIs(t, err, cryptoext.ErrTokenInvalid)
+}
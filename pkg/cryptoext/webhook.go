@@ -0,0 +1,257 @@
+package cryptoext
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gosom/toolkit/pkg/errorsext"
+)
+
+const (
+	// WebhookSignatureHeader is the default header carrying the signature.
+	WebhookSignatureHeader = "Webhook-Signature"
+	// DefaultWebhookTolerance is the default maximum age of a signature.
+	DefaultWebhookTolerance = 5 * time.Minute
+	// DefaultWebhookMaxBodySize is the default body limit of the middleware.
+	DefaultWebhookMaxBodySize = 1 << 20
+)
+
+var (
+	ErrWebhookMalformed = errors.New("malformed webhook signature")
+	ErrWebhookSignature = errors.New("invalid webhook signature")
+	ErrWebhookTimestamp = errors.New("webhook timestamp outside the tolerance")
+	ErrNoWebhookSecrets = errors.New("no webhook secrets")
+
+	ErrInvalidWebhookTolerance = errors.New("webhook tolerance must be positive")
+)
+
+// WebhookSigner signs outgoing webhooks using the t=timestamp,v1=signature
+// scheme, where the signature is the hex encoded HMAC-SHA256 of
+// "<timestamp>.<payload>".
+type WebhookSigner struct {
+	secrets []string
+}
+
+// NewWebhookSigner creates a signer. During secret rotation pass both the
+// new and the old secret; a v1 signature is emitted for each of them, so
+// receivers accept the webhook with either.
+func NewWebhookSigner(secrets ...string) (*WebhookSigner, error) {
+	if len(secrets) == 0 {
+		return nil, errorsext.WithStack(ErrNoWebhookSecrets)
+	}
+
+	return &WebhookSigner{secrets: secrets}, nil
+}
+
+// Sign returns the signature header value for payload sent at time at.
+func (s *WebhookSigner) Sign(payload []byte, at time.Time) string {
+	ts := at.Unix()
+
+	var sb strings.Builder
+
+	sb.WriteString("t=")
+	sb.WriteString(strconv.FormatInt(ts, 10))
+
+	for _, secret := range s.secrets {
+		sb.WriteString(",v1=")
+		sb.WriteString(hex.EncodeToString(webhookMAC(secret, ts, payload)))
+	}
+
+	return sb.String()
+}
+
+// SignRequest sets the WebhookSignatureHeader of req for payload, which must be the request body.
+func (s *WebhookSigner) SignRequest(req *http.Request, payload []byte) {
+	req.Header.Set(WebhookSignatureHeader, s.Sign(payload, time.Now()))
+}
+
+type WebhookOption func(*WebhookVerifier) error
+
+// WithWebhookTolerance sets how old (or how far in the future) a timestamp may be.
+// It fails with ErrInvalidWebhookTolerance unless tolerance is positive.
+func WithWebhookTolerance(tolerance time.Duration) WebhookOption {
+	return func(v *WebhookVerifier) error {
+		if tolerance <= 0 {
+			return ErrInvalidWebhookTolerance
+		}
+
+		v.tolerance = tolerance
+
+		return nil
+	}
+}
+
+// WithoutWebhookTolerance disables the timestamp check, so replayed
+// webhooks are accepted for as long as their secret is.
+func WithoutWebhookTolerance() WebhookOption {
+	return func(v *WebhookVerifier) error {
+		v.tolerance = 0
+
+		return nil
+	}
+}
+
+// WithWebhookHeader sets the header the middleware reads the signature from.
+func WithWebhookHeader(header string) WebhookOption {
+	return func(v *WebhookVerifier) error {
+		v.header = header
+
+		return nil
+	}
+}
+
+// WithWebhookMaxBodySize sets the maximum body size the middleware buffers.
+func WithWebhookMaxBodySize(size int64) WebhookOption {
+	return func(v *WebhookVerifier) error {
+		v.maxBodySize = size
+
+		return nil
+	}
+}
+
+// WebhookVerifier verifies signatures produced by WebhookSigner or any
+// provider using the same scheme. A webhook is accepted if any of its v1
+// signatures matches any of the secrets, which allows rotating secrets on
+// either side without downtime.
+type WebhookVerifier struct {
+	secrets     []string
+	tolerance   time.Duration
+	header      string
+	maxBodySize int64
+}
+
+// NewWebhookVerifier creates a verifier accepting signatures of any of secrets.
+func NewWebhookVerifier(secrets []string, opts ...WebhookOption) (*WebhookVerifier, error) {
+	if len(secrets) == 0 {
+		return nil, errorsext.WithStack(ErrNoWebhookSecrets)
+	}
+
+	ans := WebhookVerifier{
+		secrets:     secrets,
+		tolerance:   DefaultWebhookTolerance,
+		header:      WebhookSignatureHeader,
+		maxBodySize: DefaultWebhookMaxBodySize,
+	}
+
+	for _, opt := range opts {
+		if err := opt(&ans); err != nil {
+			return nil, errorsext.WithStack(err)
+		}
+	}
+
+	return &ans, nil
+}
+
+// Verify checks the signature header value against payload.
+func (v *WebhookVerifier) Verify(header string, payload []byte) error {
+	var (
+		ts         int64
+		hasTS      bool
+		signatures [][]byte
+	)
+
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return errorsext.WithStack(ErrWebhookMalformed)
+		}
+
+		switch key {
+		case "t":
+			parsed, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return errorsext.WithStack(ErrWebhookMalformed)
+			}
+
+			ts = parsed
+			hasTS = true
+		case "v1":
+			sig, err := hex.DecodeString(value)
+			if err != nil {
+				return errorsext.WithStack(ErrWebhookMalformed)
+			}
+
+			signatures = append(signatures, sig)
+		}
+	}
+
+	if !hasTS || len(signatures) == 0 {
+		return errorsext.WithStack(ErrWebhookMalformed)
+	}
+
+	valid := false
+
+	for _, secret := range v.secrets {
+		expected := webhookMAC(secret, ts, payload)
+
+		for _, sig := range signatures {
+			if hmac.Equal(sig, expected) {
+				valid = true
+			}
+		}
+	}
+
+	if !valid {
+		return errorsext.WithStack(ErrWebhookSignature)
+	}
+
+	if v.tolerance > 0 {
+		age := time.Since(time.Unix(ts, 0))
+		if age > v.tolerance || age < -v.tolerance {
+			return errorsext.WithStack(ErrWebhookTimestamp)
+		}
+	}
+
+	return nil
+}
+
+// Middleware verifies inbound requests before calling next. The body is
+// read up to the configured limit and replaced with an in-memory copy, so
+// next can read it again. Requests with a body over the limit get a 413 and
+// requests failing verification a 401.
+func (v *WebhookVerifier) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, v.maxBodySize))
+		if err != nil {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+
+				return
+			}
+
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+
+			return
+		}
+
+		if err := v.Verify(r.Header.Get(v.header), body); err != nil {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+
+			return
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		r.ContentLength = int64(len(body))
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func webhookMAC(secret string, ts int64, payload []byte) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+
+	h.Write([]byte(strconv.FormatInt(ts, 10)))
+	h.Write([]byte("."))
+	h.Write(payload)
+
+	return h.Sum(nil)
+}
@@ -0,0 +1,85 @@
+package cryptoext_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/toolkit/pkg/cryptoext"
+)
+
+func Test_Webhook_Verify(t *testing.T) {
+	t.Parallel()
+
+	payload := []byte(`{"event":"invoice.paid"}`)
+
+	signer, err := cryptoext.NewWebhookSigner("new-secret", "old-secret")
+	require.NoError(t, err)
+
+	header := signer.Sign(payload, time.Now())
+
+	verifier, err := cryptoext.NewWebhookVerifier([]string{"old-secret"})
+	require.NoError(t, err)
+
+	require.NoError(t, verifier.Verify(header, payload))
+	require.ErrorIs(t, verifier.Verify(header, []byte(`{}`)), cryptoext.ErrWebhookSignature)
+	require.ErrorIs(t, verifier.Verify("v1=abcd", payload), cryptoext.ErrWebhookMalformed)
+
+	stale := signer.Sign(payload, time.Now().Add(-time.Hour))
+	require.ErrorIs(t, verifier.Verify(stale, payload), cryptoext.ErrWebhookTimestamp)
+
+	for _, tolerance := range []time.Duration{0, -time.Minute} {
+		_, err = cryptoext.NewWebhookVerifier([]string{"old-secret"}, cryptoext.WithWebhookTolerance(tolerance))
+		require.ErrorIs(t, err, cryptoext.ErrInvalidWebhookTolerance)
+	}
+
+	lenient, err := cryptoext.NewWebhookVerifier([]string{"old-secret"}, cryptoext.WithoutWebhookTolerance())
+	require.NoError(t, err)
+	require.NoError(t, lenient.Verify(stale, payload))
+}
+
+func Test_Webhook_Middleware(t *testing.T) {
+	t.Parallel()
+
+	payload := []byte(`{"event":"invoice.paid"}`)
+
+	signer, err := cryptoext.NewWebhookSigner("secret")
+	require.NoError(t, err)
+
+	verifier, err := cryptoext.NewWebhookVerifier([]string{"secret"}, cryptoext.WithWebhookMaxBodySize(64))
+	require.NoError(t, err)
+
+	handler := verifier.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.Equal(t, payload, body)
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/webhooks", bytes.NewReader(payload))
+	signer.SignRequest(req, payload)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/webhooks", bytes.NewReader(payload))
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	large := bytes.Repeat([]byte("x"), 100)
+	req = httptest.NewRequest(http.MethodPost, "/webhooks", bytes.NewReader(large))
+	signer.SignRequest(req, large)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+}
@@ -15,6 +15,10 @@ func (s *stacktraceError) Error() string {
 	return s.cause.Error()
 }
 
+func (s *stacktraceError) Unwrap() error {
+	return s.cause
+}
+
 func (s *stacktraceError) Stacktrace() string {
 	return s.stacktrace
 }
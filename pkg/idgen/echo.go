@@ -0,0 +1,24 @@
+package idgen
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Param decodes the path parameter name with the default provider.
+func Param(c echo.Context, name string) (int64, error) {
+	return ParamWith(c, defaultProvider, name)
+}
+
+// ParamWith decodes the path parameter name with p. IDs that fail to
+// decode are reported as an echo.HTTPError with status 404, since an
+// invalid ID cannot refer to an existing resource.
+func ParamWith(c echo.Context, p IDProvider, name string) (int64, error) {
+	id, err := p.Decode(c.Param(name))
+	if err != nil {
+		return 0, echo.NewHTTPError(http.StatusNotFound).SetInternal(err)
+	}
+
+	return id, nil
+}
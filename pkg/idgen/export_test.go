@@ -0,0 +1,10 @@
+package idgen
+
+// Unregister removes the provider registered under name, so tests can
+// register it again when run with -count.
+func Unregister(name string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	delete(registry, name)
+}
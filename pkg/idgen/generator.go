@@ -0,0 +1,281 @@
+package idgen
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gosom/toolkit/pkg/errorsext"
+)
+
+const (
+	snowflakeNodeBits     = 10
+	snowflakeSequenceBits = 12
+	snowflakeTimeBits     = 41
+
+	// MaxSnowflakeNodeID is the largest node id of a SnowflakeGenerator.
+	MaxSnowflakeNodeID = 1<<snowflakeNodeBits - 1
+
+	maxSnowflakeSequence = 1<<snowflakeSequenceBits - 1
+	maxSnowflakeTime     = 1<<snowflakeTimeBits - 1
+
+	crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+)
+
+// DefaultSnowflakeEpoch is the default epoch of a SnowflakeGenerator.
+var DefaultSnowflakeEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+var (
+	ErrInvalidNodeID      = errors.New("invalid snowflake node id")
+	ErrClockBeforeEpoch   = errors.New("clock is before the snowflake epoch")
+	ErrSnowflakeExhausted = errors.New("snowflake timestamp bits exhausted")
+)
+
+// Generator creates new unique IDs that sort by creation time.
+//
+// All implementations are safe for concurrent use and monotonic within a
+// process: when several IDs are created in the same millisecond, or the
+// clock goes backwards, each ID is still greater than the previous one.
+type Generator interface {
+	Generate() (string, error)
+}
+
+// UUIDv7Generator generates UUID version 7 values (RFC 9562).
+type UUIDv7Generator struct {
+	m monotonic
+}
+
+// NewUUIDv7Generator creates a UUIDv7Generator.
+func NewUUIDv7Generator() *UUIDv7Generator {
+	ans := UUIDv7Generator{
+		m: monotonic{now: time.Now, maxHi: 1<<10 - 1},
+	}
+
+	return &ans
+}
+
+// New returns the next UUID in binary form.
+func (g *UUIDv7Generator) New() ([16]byte, error) {
+	var ans [16]byte
+
+	ms, hi, lo, err := g.m.next()
+	if err != nil {
+		return ans, err
+	}
+
+	// 48 bits timestamp, 4 bits version, 12 bits rand_a,
+	// 2 bits variant and 62 bits rand_b.
+	randA := uint64(hi)<<2 | lo>>62
+
+	binary.BigEndian.PutUint64(ans[0:8], uint64(ms)<<16|0x7<<12|randA)
+	binary.BigEndian.PutUint64(ans[8:16], 0x2<<62|lo&(1<<62-1))
+
+	return ans, nil
+}
+
+// Generate returns the next UUID in its canonical textual form.
+func (g *UUIDv7Generator) Generate() (string, error) {
+	u, err := g.New()
+	if err != nil {
+		return "", err
+	}
+
+	buf := make([]byte, 36)
+
+	hex.Encode(buf[0:8], u[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], u[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], u[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], u[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], u[10:])
+
+	return string(buf), nil
+}
+
+// ULIDGenerator generates ULIDs (https://github.com/ulid/spec).
+type ULIDGenerator struct {
+	m monotonic
+}
+
+// NewULIDGenerator creates a ULIDGenerator.
+func NewULIDGenerator() *ULIDGenerator {
+	ans := ULIDGenerator{
+		m: monotonic{now: time.Now, maxHi: 1<<16 - 1},
+	}
+
+	return &ans
+}
+
+// Generate returns the next ULID as 26 Crockford base32 characters.
+func (g *ULIDGenerator) Generate() (string, error) {
+	ms, hi, lo, err := g.m.next()
+	if err != nil {
+		return "", err
+	}
+
+	// the 128 bit value is 48 bits timestamp followed by 80 random bits.
+	// It is encoded 5 bits at a time from the end, the first character
+	// holding the 3 most significant bits.
+	high := uint64(ms)<<16 | uint64(hi)
+
+	buf := make([]byte, 26)
+
+	for i := len(buf) - 1; i >= 0; i-- {
+		buf[i] = crockfordAlphabet[lo&31]
+		lo = lo>>5 | high<<59
+		high >>= 5
+	}
+
+	return string(buf), nil
+}
+
+// monotonic produces millisecond timestamps with up to 80 random bits,
+// split in hi and lo. Within the same millisecond the random bits are
+// incremented instead of drawn again; if they overflow the timestamp is
+// advanced by one millisecond.
+type monotonic struct {
+	mu     sync.Mutex
+	now    func() time.Time
+	maxHi  uint16
+	lastMS int64
+	hi     uint16
+	lo     uint64
+}
+
+func (m *monotonic) next() (ms int64, hi uint16, lo uint64, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ms = m.now().UnixMilli()
+
+	if ms <= m.lastMS {
+		m.lo++
+		if m.lo == 0 {
+			m.hi++
+		}
+
+		if m.hi <= m.maxHi && (m.lo != 0 || m.hi != 0) {
+			return m.lastMS, m.hi, m.lo, nil
+		}
+
+		ms = m.lastMS + 1
+	}
+
+	var buf [10]byte
+
+	if _, err := rand.Read(buf[:]); err != nil {
+		return 0, 0, 0, errorsext.WithStack(err)
+	}
+
+	m.lastMS = ms
+	m.hi = binary.BigEndian.Uint16(buf[0:2]) & m.maxHi
+	m.lo = binary.BigEndian.Uint64(buf[2:10])
+
+	return m.lastMS, m.hi, m.lo, nil
+}
+
+type SnowflakeOption func(*SnowflakeGenerator) error
+
+// WithEpoch sets the time the snowflake timestamps count from.
+// IDs can be generated for about 69 years after it.
+func WithEpoch(epoch time.Time) SnowflakeOption {
+	return func(g *SnowflakeGenerator) error {
+		g.epoch = epoch.UnixMilli()
+
+		return nil
+	}
+}
+
+// WithNodeID sets the node id, which must be unique among the processes
+// generating IDs at the same time.
+func WithNodeID(id int64) SnowflakeOption {
+	return func(g *SnowflakeGenerator) error {
+		if id < 0 || id > MaxSnowflakeNodeID {
+			return ErrInvalidNodeID
+		}
+
+		g.nodeID = id
+
+		return nil
+	}
+}
+
+// SnowflakeGenerator generates 64 bit IDs made of 41 bits of milliseconds
+// since the epoch, 10 bits of node id and a 12 bit sequence. When the
+// sequence is exhausted within a millisecond the timestamp is advanced
+// instead of waiting for the clock.
+type SnowflakeGenerator struct {
+	mu       sync.Mutex
+	now      func() time.Time
+	epoch    int64
+	nodeID   int64
+	lastMS   int64
+	sequence int64
+}
+
+// NewSnowflakeGenerator creates a SnowflakeGenerator with node id 0 and
+// DefaultSnowflakeEpoch unless configured otherwise.
+func NewSnowflakeGenerator(opts ...SnowflakeOption) (*SnowflakeGenerator, error) {
+	ans := SnowflakeGenerator{
+		now:    time.Now,
+		epoch:  DefaultSnowflakeEpoch.UnixMilli(),
+		lastMS: -1,
+	}
+
+	for _, opt := range opts {
+		if err := opt(&ans); err != nil {
+			return nil, errorsext.WithStack(err)
+		}
+	}
+
+	return &ans, nil
+}
+
+// Next returns the next ID.
+func (g *SnowflakeGenerator) Next() (int64, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := g.now().UnixMilli() - g.epoch
+	if ms < 0 {
+		return 0, errorsext.WithStack(ErrClockBeforeEpoch)
+	}
+
+	if ms <= g.lastMS {
+		ms = g.lastMS
+		g.sequence++
+
+		if g.sequence > maxSnowflakeSequence {
+			ms++
+			g.sequence = 0
+		}
+	} else {
+		g.sequence = 0
+	}
+
+	if ms > maxSnowflakeTime {
+		return 0, errorsext.WithStack(ErrSnowflakeExhausted)
+	}
+
+	g.lastMS = ms
+
+	return ms<<(snowflakeNodeBits+snowflakeSequenceBits) | g.nodeID<<snowflakeSequenceBits | g.sequence, nil
+}
+
+// Generate returns the next ID in decimal form.
+// Note that decimal strings of different length do not sort correctly.
+func (g *SnowflakeGenerator) Generate() (string, error) {
+	id, err := g.Next()
+	if err != nil {
+		return "", err
+	}
+
+	return strconv.FormatInt(id, 10), nil
+}
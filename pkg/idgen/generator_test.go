@@ -0,0 +1,120 @@
+package idgen_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/toolkit/pkg/idgen"
+)
+
+func Test_Generators(t *testing.T) {
+	t.Parallel()
+
+	snowflake, err := idgen.NewSnowflakeGenerator(idgen.WithNodeID(7))
+	require.NoError(t, err)
+
+	generators := map[string]idgen.Generator{
+		"uuidv7":    idgen.NewUUIDv7Generator(),
+		"ulid":      idgen.NewULIDGenerator(),
+		"snowflake": snowflake,
+	}
+
+	for name, g := range generators {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			prev, err := g.Generate()
+			require.NoError(t, err)
+
+			for i := 0; i < 10000; i++ {
+				id, err := g.Generate()
+				require.NoError(t, err)
+				require.Len(t, id, len(prev))
+				require.Greater(t, id, prev)
+
+				prev = id
+			}
+		})
+	}
+}
+
+func Test_Generators_Concurrent(t *testing.T) {
+	t.Parallel()
+
+	snowflake, err := idgen.NewSnowflakeGenerator()
+	require.NoError(t, err)
+
+	for _, g := range []idgen.Generator{idgen.NewUUIDv7Generator(), idgen.NewULIDGenerator(), snowflake} {
+		var (
+			mu   sync.Mutex
+			seen = map[string]bool{}
+			wg   sync.WaitGroup
+		)
+
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+
+				for j := 0; j < 1000; j++ {
+					id, err := g.Generate()
+					require.NoError(t, err)
+
+					mu.Lock()
+					seen[id] = true
+					mu.Unlock()
+				}
+			}()
+		}
+
+		wg.Wait()
+
+		require.Len(t, seen, 8000)
+	}
+}
+
+func Test_UUIDv7_Format(t *testing.T) {
+	t.Parallel()
+
+	id, err := idgen.NewUUIDv7Generator().Generate()
+	require.NoError(t, err)
+
+	require.Regexp(t, `^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, id)
+}
+
+func Test_ULID_Format(t *testing.T) {
+	t.Parallel()
+
+	id, err := idgen.NewULIDGenerator().Generate()
+	require.NoError(t, err)
+
+	require.Regexp(t, `^[0-7][0-9A-HJKMNP-TV-Z]{25}$`, id)
+}
+
+func Test_Snowflake(t *testing.T) {
+	t.Parallel()
+
+	_, err := idgen.NewSnowflakeGenerator(idgen.WithNodeID(idgen.MaxSnowflakeNodeID + 1))
+	require.ErrorIs(t, err, idgen.ErrInvalidNodeID)
+
+	g, err := idgen.NewSnowflakeGenerator(idgen.WithEpoch(time.Now().Add(time.Hour)))
+	require.NoError(t, err)
+
+	_, err = g.Next()
+	require.ErrorIs(t, err, idgen.ErrClockBeforeEpoch)
+
+	epoch := time.Now().Add(-time.Minute)
+
+	g, err = idgen.NewSnowflakeGenerator(idgen.WithEpoch(epoch), idgen.WithNodeID(5))
+	require.NoError(t, err)
+
+	id, err := g.Next()
+	require.NoError(t, err)
+
+	require.Equal(t, int64(5), id>>12&idgen.MaxSnowflakeNodeID)
+	require.InDelta(t, time.Minute.Milliseconds(), id>>22, 1000)
+}
@@ -0,0 +1,151 @@
+package idgen
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gosom/toolkit/pkg/errorsext"
+)
+
+var ErrWrongPrefix = errors.New("wrong id prefix")
+
+// Entity names the prefix of the public IDs of an entity type.
+// It is usually implemented by an empty marker type:
+//
+//	type Invoice struct{}
+//
+//	func (Invoice) IDPrefix() string { return "inv" }
+//
+//	type InvoiceID = idgen.ID[Invoice]
+type Entity interface {
+	IDPrefix() string
+}
+
+// ID is a numeric ID of entity T. In the database it is stored as the
+// number, everywhere else (JSON, text, String) as the prefix, an underscore
+// and the encoded number, e.g. inv_8QxV2kLm0p.
+//
+// The number is encoded with the provider registered under the prefix, or
+// the default provider if there is none.
+type ID[T Entity] int64
+
+// ParseID parses the public form of an ID of entity T.
+func ParseID[T Entity](s string) (ID[T], error) {
+	prefix := entityPrefix[T]()
+
+	encoded, ok := strings.CutPrefix(s, prefix+"_")
+	if !ok {
+		return 0, errorsext.WithStack(fmt.Errorf("%w: expected %s", ErrWrongPrefix, prefix))
+	}
+
+	id, err := entityProvider(prefix).Decode(encoded)
+	if err != nil {
+		return 0, err
+	}
+
+	return ID[T](id), nil
+}
+
+// Int64 returns the underlying number.
+func (id ID[T]) Int64() int64 {
+	return int64(id)
+}
+
+// String returns the public form of id, or an empty string if id is negative.
+func (id ID[T]) String() string {
+	ans, err := id.MarshalText()
+	if err != nil {
+		return ""
+	}
+
+	return string(ans)
+}
+
+func (id ID[T]) MarshalText() ([]byte, error) {
+	prefix := entityPrefix[T]()
+
+	encoded, err := encodeStrict(entityProvider(prefix), int64(id))
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(prefix + "_" + encoded), nil
+}
+
+func (id *ID[T]) UnmarshalText(b []byte) error {
+	parsed, err := ParseID[T](string(b))
+	if err != nil {
+		return err
+	}
+
+	*id = parsed
+
+	return nil
+}
+
+func (id ID[T]) MarshalJSON() ([]byte, error) {
+	s, err := id.MarshalText()
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(string(s))
+}
+
+func (id *ID[T]) UnmarshalJSON(b []byte) error {
+	var s string
+
+	if err := json.Unmarshal(b, &s); err != nil {
+		return errorsext.WithStack(err)
+	}
+
+	return id.UnmarshalText([]byte(s))
+}
+
+func (id ID[T]) Value() (driver.Value, error) {
+	return int64(id), nil
+}
+
+func (id *ID[T]) Scan(src any) error {
+	switch v := src.(type) {
+	case int64:
+		*id = ID[T](v)
+	case []byte:
+		return id.scanString(string(v))
+	case string:
+		return id.scanString(v)
+	default:
+		return errorsext.WithStack(fmt.Errorf("cannot scan %T into ID", src))
+	}
+
+	return nil
+}
+
+func (id *ID[T]) scanString(s string) error {
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return errorsext.WithStack(err)
+	}
+
+	*id = ID[T](v)
+
+	return nil
+}
+
+func entityPrefix[T Entity]() string {
+	var e T
+
+	return e.IDPrefix()
+}
+
+func entityProvider(prefix string) IDProvider {
+	if p, ok := Lookup(prefix); ok {
+		return p
+	}
+
+	return defaultProvider
+}
@@ -0,0 +1,113 @@
+package idgen_test
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/toolkit/pkg/idgen"
+)
+
+type testInvoice struct{}
+
+func (testInvoice) IDPrefix() string { return "inv" }
+
+type testUser struct{}
+
+func (testUser) IDPrefix() string { return "usr" }
+
+func Test_ID(t *testing.T) {
+	t.Parallel()
+
+	id := idgen.ID[testInvoice](42)
+
+	s := id.String()
+	require.True(t, strings.HasPrefix(s, "inv_"))
+
+	parsed, err := idgen.ParseID[testInvoice](s)
+	require.NoError(t, err)
+	require.Equal(t, id, parsed)
+
+	_, err = idgen.ParseID[testUser](s)
+	require.ErrorIs(t, err, idgen.ErrWrongPrefix)
+
+	_, err = idgen.ParseID[testInvoice]("inv_")
+	require.ErrorIs(t, err, idgen.ErrEmptyID)
+
+	require.Empty(t, idgen.ID[testInvoice](-1).String())
+}
+
+type testDecimal struct{}
+
+func (testDecimal) IDPrefix() string { return "dec" }
+
+// decimalProvider implements only IDProvider.
+type decimalProvider struct{}
+
+func (decimalProvider) Encode(id int64) string {
+	return strconv.FormatInt(id, 10)
+}
+
+func (decimalProvider) Decode(s string) (int64, error) {
+	return strconv.ParseInt(s, 10, 64)
+}
+
+func Test_ID_CustomProvider(t *testing.T) {
+	t.Parallel()
+
+	idgen.Register("dec", decimalProvider{})
+	t.Cleanup(func() { idgen.Unregister("dec") })
+
+	id := idgen.ID[testDecimal](42)
+	require.Equal(t, "dec_42", id.String())
+
+	parsed, err := idgen.ParseID[testDecimal]("dec_42")
+	require.NoError(t, err)
+	require.Equal(t, id, parsed)
+}
+
+func Test_ID_JSON(t *testing.T) {
+	t.Parallel()
+
+	type invoice struct {
+		ID     idgen.ID[testInvoice] `json:"id"`
+		UserID idgen.ID[testUser]    `json:"user_id"`
+	}
+
+	in := invoice{ID: 1, UserID: 2}
+
+	data, err := json.Marshal(in)
+	require.NoError(t, err)
+	require.Contains(t, string(data), `"id":"inv_`)
+
+	var out invoice
+
+	require.NoError(t, json.Unmarshal(data, &out))
+	require.Equal(t, in, out)
+
+	swapped := strings.Replace(string(data), `"user_id":"usr_`, `"user_id":"inv_`, 1)
+	require.ErrorIs(t, json.Unmarshal([]byte(swapped), &out), idgen.ErrWrongPrefix)
+}
+
+func Test_ID_SQL(t *testing.T) {
+	t.Parallel()
+
+	id := idgen.ID[testInvoice](42)
+
+	v, err := id.Value()
+	require.NoError(t, err)
+	require.Equal(t, int64(42), v)
+
+	var scanned idgen.ID[testInvoice]
+
+	require.NoError(t, scanned.Scan(int64(42)))
+	require.Equal(t, id, scanned)
+
+	require.NoError(t, scanned.Scan([]byte("7")))
+	require.Equal(t, int64(7), scanned.Int64())
+
+	require.Error(t, scanned.Scan(1.5))
+}
@@ -1,58 +1,364 @@
 package idgen
 
-import "github.com/sqids/sqids-go"
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/sqids/sqids-go"
+
+	"github.com/gosom/toolkit/pkg/errorsext"
+)
+
+// DefaultAlphabet is the alphabet used when no alphabet is configured.
+const DefaultAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+const defaultMinLength = 10
+
+var (
+	ErrInvalidMinLength = errors.New("min length must be between 0 and 255")
+	ErrEmptyID          = errors.New("empty id")
+	ErrInvalidID        = errors.New("invalid id")
+	ErrNonCanonicalID   = errors.New("non canonical id")
+	ErrNegativeID       = errors.New("negative id")
+	ErrIDArity          = errors.New("id has the wrong number of values")
+	ErrNoValues         = errors.New("no values to encode")
+	ErrEncode           = errors.New("id cannot be encoded")
+)
 
 var (
 	defaultProvider = New()
+
+	registryMu sync.RWMutex
+	registry   = map[string]IDProvider{}
 )
 
+// IDProvider converts numeric IDs to opaque strings and back.
+//
+// Encode returns an empty string for IDs it cannot encode, such as negative
+// IDs; see EncodeStrict. Decode fails with
+// ErrEmptyID, ErrInvalidID or ErrNonCanonicalID, the latter for strings that
+// decode to an ID but are not what Encode produces for it, so every ID has
+// exactly one valid string.
 type IDProvider interface {
 	Encode(int64) string
 	Decode(string) (int64, error)
 }
 
-func New() IDProvider {
-	const length = 10
+// MultiIDProvider is an IDProvider that also encodes composite keys, e.g. a
+// tenant id and an object id. DecodeMany fails with ErrIDArity unless the
+// string holds exactly n values.
+type MultiIDProvider interface {
+	IDProvider
+	EncodeMany(...int64) (string, error)
+	DecodeMany(string, int) ([]int64, error)
+}
+
+type options struct {
+	sqids  sqids.Options
+	secret string
+}
+
+type Option func(*options) error
+
+// WithAlphabet sets the alphabet the IDs are made of.
+func WithAlphabet(alphabet string) Option {
+	return func(o *options) error {
+		o.sqids.Alphabet = alphabet
+
+		return nil
+	}
+}
+
+// WithMinLength sets the minimum length of the IDs. Shorter IDs are padded.
+func WithMinLength(length int) Option {
+	return func(o *options) error {
+		if length < 0 || length > math.MaxUint8 {
+			return ErrInvalidMinLength
+		}
+
+		o.sqids.MinLength = uint8(length)
+
+		return nil
+	}
+}
+
+// WithBlocklist adds words that never appear in the generated IDs to the
+// default blocklist.
+func WithBlocklist(words ...string) Option {
+	return func(o *options) error {
+		// sqids only uses its default blocklist when none is set
+		if o.sqids.Blocklist == nil {
+			o.sqids.Blocklist = sqids.Blocklist()
+		}
+
+		o.sqids.Blocklist = append(o.sqids.Blocklist, words...)
+
+		return nil
+	}
+}
+
+// WithSecret shuffles the alphabet deterministically using secret, so IDs
+// of providers with different secrets cannot be decoded by each other.
+// Changing the secret changes every ID, so keep it stable.
+func WithSecret(secret string) Option {
+	return func(o *options) error {
+		o.secret = secret
+
+		return nil
+	}
+}
+
+// NewProvider creates a provider with the given options.
+// Without options it is equivalent to the default provider.
+//
+// NewProvider and New return a MultiIDProvider, which embeds IDProvider, so
+// callers storing the result in an IDProvider are not affected.
+func NewProvider(opts ...Option) (MultiIDProvider, error) {
+	o := options{
+		sqids: sqids.Options{
+			Alphabet:  DefaultAlphabet,
+			MinLength: defaultMinLength,
+		},
+	}
+
+	for _, opt := range opts {
+		if err := opt(&o); err != nil {
+			return nil, errorsext.WithStack(err)
+		}
+	}
 
-	s, err := sqids.New(sqids.Options{
-		MinLength: length,
-	})
+	if o.secret != "" {
+		o.sqids.Alphabet = ShuffleAlphabet(o.sqids.Alphabet, o.secret)
+	}
 
+	s, err := sqids.New(o.sqids)
 	if err != nil {
-		panic(err)
+		return nil, errorsext.WithStack(err)
 	}
 
 	ans := sqidProvider{
 		s: s,
 	}
 
-	return &ans
+	return &ans, nil
+}
+
+// New is like NewProvider but panics on error.
+func New(opts ...Option) MultiIDProvider {
+	ans, err := NewProvider(opts...)
+	if err != nil {
+		panic(err)
+	}
+
+	return ans
+}
+
+// ShuffleAlphabet returns a permutation of alphabet derived from secret.
+// The same alphabet and secret always give the same permutation.
+func ShuffleAlphabet(alphabet, secret string) string {
+	ans := []byte(alphabet)
+	stream := newShuffleStream(secret)
+
+	for i := len(ans) - 1; i > 0; i-- {
+		j := stream.intn(uint32(i + 1))
+		ans[i], ans[j] = ans[j], ans[i]
+	}
+
+	return string(ans)
+}
+
+// Register registers p under name, typically an entity type such as
+// "invoice". It panics if name is already registered.
+func Register(name string, p IDProvider) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, ok := registry[name]; ok {
+		panic(fmt.Sprintf("idgen: provider %q already registered", name))
+	}
+
+	registry[name] = p
+}
+
+// Lookup returns the provider registered under name.
+func Lookup(name string) (IDProvider, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	p, ok := registry[name]
+
+	return p, ok
+}
+
+// For returns the provider registered under name.
+// It panics if there is none.
+func For(name string) IDProvider {
+	p, ok := Lookup(name)
+	if !ok {
+		panic(fmt.Sprintf("idgen: provider %q is not registered", name))
+	}
+
+	return p
 }
 
 func Encode(id int64) string {
 	return defaultProvider.Encode(id)
 }
 
+// EncodeStrict is like Encode but fails with ErrNegativeID for negative IDs
+// and ErrEncode for other IDs that cannot be encoded.
+func EncodeStrict(id int64) (string, error) {
+	return encodeStrict(defaultProvider, id)
+}
+
 func Decode(s string) (int64, error) {
 	return defaultProvider.Decode(s)
 }
 
+func EncodeMany(ids ...int64) (string, error) {
+	return defaultProvider.EncodeMany(ids...)
+}
+
+func DecodeMany(s string, n int) ([]int64, error) {
+	return defaultProvider.DecodeMany(s, n)
+}
+
 type sqidProvider struct {
 	s *sqids.Sqids
 }
 
 func (p *sqidProvider) Encode(id int64) string {
-	ans, _ := p.s.Encode([]uint64{uint64(id)})
+	ans, _ := p.EncodeMany(id)
 
 	return ans
 }
 
 func (p *sqidProvider) Decode(s string) (int64, error) {
-	ans := p.s.Decode(s)
+	ans, err := p.DecodeMany(s, 1)
+	if err != nil {
+		return 0, err
+	}
+
+	return ans[0], nil
+}
+
+func (p *sqidProvider) EncodeMany(ids ...int64) (string, error) {
+	if len(ids) == 0 {
+		return "", errorsext.WithStack(ErrNoValues)
+	}
+
+	values := make([]uint64, len(ids))
+
+	for i, id := range ids {
+		if id < 0 {
+			return "", errorsext.WithStack(ErrNegativeID)
+		}
+
+		values[i] = uint64(id)
+	}
+
+	ans, err := p.s.Encode(values)
+	if err != nil {
+		return "", errorsext.WithStack(err)
+	}
+
+	return ans, nil
+}
+
+func (p *sqidProvider) DecodeMany(s string, n int) ([]int64, error) {
+	if s == "" {
+		return nil, errorsext.WithStack(ErrEmptyID)
+	}
+
+	values := p.s.Decode(s)
+
+	if len(values) == 0 {
+		return nil, errorsext.WithStack(ErrInvalidID)
+	}
+
+	if len(values) != n {
+		return nil, errorsext.WithStack(ErrIDArity)
+	}
 
-	if len(ans) == 0 {
-		return 0, nil
+	ans := make([]int64, len(values))
+
+	for i, v := range values {
+		if v > math.MaxInt64 {
+			return nil, errorsext.WithStack(ErrInvalidID)
+		}
+
+		ans[i] = int64(v)
+	}
+
+	canonical, err := p.EncodeMany(ans...)
+	if err != nil {
+		return nil, err
+	}
+
+	if canonical != s {
+		return nil, errorsext.WithStack(ErrNonCanonicalID)
+	}
+
+	return ans, nil
+}
+
+func encodeStrict(p IDProvider, id int64) (string, error) {
+	if id < 0 {
+		return "", errorsext.WithStack(ErrNegativeID)
+	}
+
+	ans := p.Encode(id)
+	if ans == "" {
+		return "", errorsext.WithStack(ErrEncode)
+	}
+
+	return ans, nil
+}
+
+// shuffleStream is a deterministic stream of uint32 values derived from
+// SHA-256(secret || counter).
+type shuffleStream struct {
+	secret  string
+	counter uint64
+	buf     []byte
+}
+
+func newShuffleStream(secret string) *shuffleStream {
+	return &shuffleStream{secret: secret}
+}
+
+func (s *shuffleStream) uint32() uint32 {
+	if len(s.buf) < 4 {
+		var ctr [8]byte
+
+		binary.BigEndian.PutUint64(ctr[:], s.counter)
+		s.counter++
+
+		h := sha256.New()
+		h.Write([]byte(s.secret))
+		h.Write(ctr[:])
+
+		s.buf = h.Sum(nil)
 	}
 
-	return int64(ans[0]), nil
+	ans := binary.BigEndian.Uint32(s.buf)
+	s.buf = s.buf[4:]
+
+	return ans
+}
+
+// intn returns a uniform value in [0, n) using rejection sampling.
+func (s *shuffleStream) intn(n uint32) uint32 {
+	limit := math.MaxUint32 - math.MaxUint32%n
+
+	for {
+		v := s.uint32()
+		if v < limit {
+			return v % n
+		}
+	}
 }
@@ -1,8 +1,11 @@
 package idgen_test
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
+	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/require"
 
 	"github.com/gosom/toolkit/pkg/idgen"
@@ -45,3 +48,138 @@ func Test_Encode_Specific(t *testing.T) {
 
 	require.Equal(t, "s18jaGonyx", sID)
 }
+
+func Test_New_Options(t *testing.T) {
+	t.Parallel()
+
+	invoices := idgen.New(idgen.WithSecret("invoice-secret"), idgen.WithMinLength(12))
+	users := idgen.New(idgen.WithSecret("user-secret"))
+
+	sID := invoices.Encode(42)
+	require.Len(t, sID, 12)
+	require.NotEqual(t, idgen.Encode(42), users.Encode(42))
+
+	decoded, err := invoices.Decode(sID)
+	require.NoError(t, err)
+	require.Equal(t, int64(42), decoded)
+
+	_, err = users.Decode(sID)
+	require.Error(t, err)
+
+	_, err = idgen.NewProvider(idgen.WithMinLength(300))
+	require.ErrorIs(t, err, idgen.ErrInvalidMinLength)
+
+	_, err = idgen.NewProvider(idgen.WithAlphabet("aab"))
+	require.Error(t, err)
+
+	sID = idgen.Encode(16)
+	require.NotEqual(t, sID, idgen.New(idgen.WithBlocklist(sID)).Encode(16))
+
+	// "aho1e" is on the default blocklist, which still applies
+	withBlocklist := idgen.New(idgen.WithMinLength(0), idgen.WithBlocklist("foo")).Encode(4572721)
+	require.Equal(t, "JExTR", withBlocklist)
+}
+
+func Test_ShuffleAlphabet(t *testing.T) {
+	t.Parallel()
+
+	shuffled := idgen.ShuffleAlphabet(idgen.DefaultAlphabet, "secret")
+
+	require.Equal(t, shuffled, idgen.ShuffleAlphabet(idgen.DefaultAlphabet, "secret"))
+	require.NotEqual(t, shuffled, idgen.ShuffleAlphabet(idgen.DefaultAlphabet, "other"))
+	require.ElementsMatch(t, []byte(idgen.DefaultAlphabet), []byte(shuffled))
+	require.Equal(t, "1iasuUyQ4okKjE90XLVhMbrIxR2T8vmAYf6WzFnOJSNpdDlgZCtHBqw7Pe5c3G", shuffled)
+}
+
+func Test_Registry(t *testing.T) {
+	t.Parallel()
+
+	p := idgen.New(idgen.WithSecret("registry-test"))
+	idgen.Register("registry-test", p)
+	t.Cleanup(func() { idgen.Unregister("registry-test") })
+
+	require.Same(t, p, idgen.For("registry-test"))
+	require.Panics(t, func() { idgen.Register("registry-test", p) })
+	require.Panics(t, func() { idgen.For("missing") })
+
+	_, ok := idgen.Lookup("missing")
+	require.False(t, ok)
+}
+
+func Test_Decode_Strict(t *testing.T) {
+	t.Parallel()
+
+	require.Empty(t, idgen.Encode(-1))
+
+	_, err := idgen.EncodeStrict(-1)
+	require.ErrorIs(t, err, idgen.ErrNegativeID)
+
+	_, err = idgen.Decode("")
+	require.ErrorIs(t, err, idgen.ErrEmptyID)
+
+	_, err = idgen.Decode("xyz-!")
+	require.ErrorIs(t, err, idgen.ErrInvalidID)
+
+	sID, err := idgen.EncodeStrict(16)
+	require.NoError(t, err)
+	require.Equal(t, idgen.Encode(16), sID)
+
+	_, err = idgen.Decode(sID[:len(sID)-1])
+	require.Error(t, err)
+
+	_, err = idgen.Decode(sID + "a")
+	require.Error(t, err)
+
+	short := idgen.New(idgen.WithMinLength(0)).Encode(16)
+
+	_, err = idgen.Decode(short)
+	require.ErrorIs(t, err, idgen.ErrNonCanonicalID)
+}
+
+func Test_Param(t *testing.T) {
+	t.Parallel()
+
+	sID := idgen.Encode(16)
+
+	e := echo.New()
+
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+	c.SetParamNames("id")
+	c.SetParamValues(sID)
+
+	id, err := idgen.Param(c, "id")
+	require.NoError(t, err)
+	require.Equal(t, int64(16), id)
+
+	c.SetParamValues("xyz")
+
+	_, err = idgen.Param(c, "id")
+
+	var httpErr *echo.HTTPError
+
+	require.ErrorAs(t, err, &httpErr)
+	require.Equal(t, http.StatusNotFound, httpErr.Code)
+}
+
+func Test_EncodeMany(t *testing.T) {
+	t.Parallel()
+
+	sID, err := idgen.EncodeMany(3, 1000)
+	require.NoError(t, err)
+
+	ids, err := idgen.DecodeMany(sID, 2)
+	require.NoError(t, err)
+	require.Equal(t, []int64{3, 1000}, ids)
+
+	_, err = idgen.DecodeMany(sID, 3)
+	require.ErrorIs(t, err, idgen.ErrIDArity)
+
+	_, err = idgen.Decode(sID)
+	require.ErrorIs(t, err, idgen.ErrIDArity)
+
+	_, err = idgen.EncodeMany()
+	require.ErrorIs(t, err, idgen.ErrNoValues)
+
+	_, err = idgen.EncodeMany(1, -1)
+	require.ErrorIs(t, err, idgen.ErrNegativeID)
+}
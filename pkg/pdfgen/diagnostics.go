@@ -0,0 +1,125 @@
+package pdfgen
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var ErrWarnings = errors.New("renderer reported warnings")
+
+type Level string
+
+const (
+	LevelWarning Level = "warning"
+	LevelError   Level = "error"
+)
+
+// Diagnostic is a warning or error reported by a renderer, such as a
+// missing font or an image that could not be loaded.
+type Diagnostic struct {
+	Level   Level
+	Message string
+}
+
+// Result describes a completed render.
+type Result struct {
+	Diagnostics []Diagnostic
+	// Stderr is the unparsed standard error of the renderer.
+	Stderr string
+}
+
+// Warnings returns the diagnostics with LevelWarning.
+func (r *Result) Warnings() []Diagnostic {
+	return r.filter(LevelWarning)
+}
+
+// Errors returns the diagnostics with LevelError.
+func (r *Result) Errors() []Diagnostic {
+	return r.filter(LevelError)
+}
+
+func (r *Result) filter(level Level) []Diagnostic {
+	var ans []Diagnostic
+
+	for _, d := range r.Diagnostics {
+		if d.Level == level {
+			ans = append(ans, d)
+		}
+	}
+
+	return ans
+}
+
+// RenderError is returned when a renderer fails, or in strict mode when it
+// reports diagnostics. ExitCode is -1 if the renderer did not exit normally.
+type RenderError struct {
+	ExitCode    int
+	Stderr      string
+	Diagnostics []Diagnostic
+	Err         error
+}
+
+func (e *RenderError) Error() string {
+	msg := fmt.Sprintf("pdf rendering failed (exit code %d): %v", e.ExitCode, e.Err)
+
+	for _, d := range e.Diagnostics {
+		if d.Level == LevelError {
+			return msg + ": " + d.Message
+		}
+	}
+
+	return msg
+}
+
+func (e *RenderError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	// WeasyPrint and wkhtmltopdf: "WARNING: message", "Error: message".
+	prefixDiagnostic = regexp.MustCompile(`^(?i)(warning|error|critical):\s*(.*)$`)
+	// Chromium: "[1019/101010.123:INFO:CONSOLE(12)] message". Only console
+	// messages of the document, including failed resource loads, are
+	// diagnostics; Chromium also logs dbus, GPU and sandbox errors on
+	// startup that have nothing to do with the document.
+	chromiumDiagnostic = regexp.MustCompile(`^\[[^\]]*:(INFO|WARNING|ERROR|FATAL):CONSOLE\(\d+\)\]\s*(.*)$`)
+)
+
+// parseDiagnostics extracts the warnings and errors from the standard
+// error of WeasyPrint or wkhtmltopdf. Other lines are ignored.
+func parseDiagnostics(stderr string) []Diagnostic {
+	return matchDiagnostics(stderr, prefixDiagnostic)
+}
+
+// parseChromiumDiagnostics extracts the console messages of the document
+// from the standard error of Chromium.
+func parseChromiumDiagnostics(stderr string) []Diagnostic {
+	return matchDiagnostics(stderr, chromiumDiagnostic)
+}
+
+// matchDiagnostics returns a diagnostic for every line of stderr matching
+// re, whose groups are the level and the message.
+func matchDiagnostics(stderr string, re *regexp.Regexp) []Diagnostic {
+	var ans []Diagnostic
+
+	scanner := bufio.NewScanner(strings.NewReader(stderr))
+
+	for scanner.Scan() {
+		m := re.FindStringSubmatch(strings.TrimSpace(scanner.Text()))
+		if m == nil {
+			continue
+		}
+
+		level := LevelError
+		if strings.EqualFold(m[1], "warning") || m[1] == "INFO" {
+			level = LevelWarning
+		}
+
+		ans = append(ans, Diagnostic{Level: level, Message: m[2]})
+	}
+
+	return ans
+}
@@ -0,0 +1,1196 @@
+package pdfgen
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"image"
+	_ "image/gif"  // register the gif decoder for <img>
+	_ "image/jpeg" // register the jpeg decoder for <img>
+	_ "image/png"  // register the png decoder for <img>
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/font"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/text/encoding/charmap"
+
+	"github.com/gosom/toolkit/pkg/errorsext"
+)
+
+const (
+	defaultFontSize = 11
+	lineHeightRatio = 1.2
+	pxToPt          = 0.75
+	listIndent      = 18
+	cellPadding     = 4
+	borderWidth     = 0.5
+	sectionGap      = 6
+)
+
+// GoRenderer renders a subset of HTML without external programs. It is
+// meant for simple documents such as receipts and labels, and as a
+// fallback when no other renderer is installed.
+//
+// Supported are:
+//   - headings, paragraphs, div and other block elements, blockquote, pre
+//   - b, strong, i, em, code, small and br inside text
+//   - ul and ol lists
+//   - tables without colspan and rowspan; columns have equal widths and
+//     rows of thead are repeated on every page
+//   - PNG, JPEG and GIF images as data URIs, from RenderOptions.Assets or
+//     from a local RenderOptions.BaseURL, sized by the width and height
+//     attributes
+//   - hr and page breaks with the style page-break-before/after: always
+//     or break-before/after: page
+//   - the style properties text-align, font-size, font-weight and
+//     font-style
+//   - header and footer elements that are children of body, repeated on
+//     every page; {page} and {pages} in them are replaced with the page
+//     number and the page count
+//
+// Text uses the standard Helvetica and Courier fonts, so only characters
+// of the Windows-1252 character set can be displayed. Stylesheets, colors
+// and the media type are ignored. Metadata and attachments are supported,
+// variants are not.
+type GoRenderer struct{}
+
+// NewGoRenderer creates a GoRenderer.
+func NewGoRenderer() *GoRenderer {
+	return &GoRenderer{}
+}
+
+func (r *GoRenderer) Render(ctx context.Context, w io.Writer, doc io.Reader, opts RenderOptions) (*Result, error) {
+	if opts.Variant != "" {
+		return nil, errorsext.WithStack(fmt.Errorf("%w: %s", ErrUnsupportedVariant, opts.Variant))
+	}
+
+	if err := validateAttachments(opts.Attachments); err != nil {
+		return nil, err
+	}
+
+	root, err := html.Parse(doc)
+	if err != nil {
+		return nil, errorsext.WithStack(err)
+	}
+
+	width, height, err := pageSize(opts.Page)
+	if err != nil {
+		return nil, err
+	}
+
+	b := boxBuilder{opts: opts, images: map[string]*imageBox{}}
+
+	var body, header, footer []box
+
+	if n := findElement(root, atom.Body); n != nil {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			switch {
+			case c.DataAtom == atom.Header && header == nil:
+				header = b.blocks(c, textStyle{size: defaultFontSize}, 0)
+			case c.DataAtom == atom.Footer && footer == nil:
+				footer = b.blocks(c, textStyle{size: defaultFontSize}, 0)
+			}
+		}
+
+		body = b.blocks(n, textStyle{size: defaultFontSize}, 0)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, errorsext.WithStack(err)
+	}
+
+	l := pageLayout{doc: &pdfDocument{
+		width:       width,
+		height:      height,
+		info:        opts.Metadata.info(),
+		attachments: opts.Attachments,
+	}}
+
+	if err := l.init(opts.Page, header, footer); err != nil {
+		return nil, err
+	}
+
+	for _, img := range b.order {
+		img.index = l.doc.addImage(img.img)
+	}
+
+	l.newPage()
+	l.flow(body, l.left, l.right-l.left)
+	l.decorate(header, footer)
+
+	var buf bytes.Buffer
+
+	if err := l.doc.write(&buf); err != nil {
+		return nil, err
+	}
+
+	if opts.Validate {
+		if err := ValidatePDF(bytes.NewReader(buf.Bytes())); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return nil, errorsext.WithStack(err)
+	}
+
+	return &Result{Diagnostics: append(b.diagnostics, l.diagnostics...)}, nil
+}
+
+// textStyle is the style of a run of text.
+type textStyle struct {
+	bold   bool
+	italic bool
+	mono   bool
+	pre    bool
+	size   float64
+}
+
+func (s textStyle) fontIndex() int {
+	switch {
+	case s.mono && s.bold:
+		return 5
+	case s.mono:
+		return 4
+	case s.bold && s.italic:
+		return 3
+	case s.italic:
+		return 2
+	case s.bold:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (s textStyle) width(text string) float64 {
+	name := pdfFonts[s.fontIndex()]
+
+	var ans int
+
+	for i := 0; i < len(text); i++ {
+		ans += font.CharWidth(name, rune(text[i]))
+	}
+
+	return float64(ans) * s.size / 1000
+}
+
+func (s textStyle) lineHeight() float64 {
+	return s.size * lineHeightRatio
+}
+
+// run is text with a style; "\n" forces a line break.
+type run struct {
+	text  string
+	style textStyle
+}
+
+type box interface{}
+
+type paraBox struct {
+	runs        []run
+	style       textStyle
+	align       string
+	indent      float64
+	marker      string
+	spaceBefore float64
+	spaceAfter  float64
+}
+
+type imageBox struct {
+	img    *pdfImage
+	index  int
+	width  float64
+	height float64
+	indent float64
+	align  string
+}
+
+type ruleBox struct {
+	indent float64
+}
+
+type breakBox struct{}
+
+type tableBox struct {
+	rows   []tableRow
+	cols   int
+	indent float64
+}
+
+type tableRow struct {
+	cells  [][]box
+	header []bool
+	thead  bool
+}
+
+// boxBuilder converts the HTML tree to boxes.
+type boxBuilder struct {
+	opts        RenderOptions
+	images      map[string]*imageBox
+	order       []*imageBox
+	diagnostics []Diagnostic
+}
+
+var headingSizes = map[atom.Atom]float64{
+	atom.H1: 24,
+	atom.H2: 18,
+	atom.H3: 15,
+	atom.H4: 13,
+	atom.H5: 11,
+	atom.H6: 10,
+}
+
+func isBlock(a atom.Atom) bool {
+	switch a {
+	case atom.P, atom.Div, atom.Section, atom.Article, atom.Main, atom.Aside, atom.Nav,
+		atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6,
+		atom.Ul, atom.Ol, atom.Li, atom.Table, atom.Hr, atom.Blockquote, atom.Pre,
+		atom.Address, atom.Figure, atom.Figcaption, atom.Header, atom.Footer,
+		atom.Dl, atom.Dt, atom.Dd, atom.Form, atom.Fieldset:
+		return true
+	default:
+		return false
+	}
+}
+
+func isSkipped(a atom.Atom) bool {
+	switch a {
+	case atom.Head, atom.Script, atom.Style, atom.Title, atom.Template, atom.Noscript:
+		return true
+	default:
+		return false
+	}
+}
+
+// blocks returns the boxes for the children of n.
+func (b *boxBuilder) blocks(n *html.Node, st textStyle, indent float64) []box {
+	var (
+		ans     []box
+		pending []run
+	)
+
+	align := styleProperty(n, "text-align")
+
+	flush := func() {
+		if len(pending) > 0 {
+			ans = append(ans, &paraBox{runs: pending, style: st, align: align, indent: indent})
+			pending = nil
+		}
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && n.DataAtom == atom.Body && (c.DataAtom == atom.Header || c.DataAtom == atom.Footer) {
+			continue
+		}
+
+		switch {
+		case c.Type == html.TextNode:
+			pending = append(pending, textRuns(c.Data, st)...)
+		case c.Type != html.ElementNode || isSkipped(c.DataAtom):
+		case c.DataAtom == atom.Img:
+			flush()
+
+			if img := b.image(c, indent); img != nil {
+				ans = append(ans, img)
+			}
+		case isBlock(c.DataAtom):
+			flush()
+
+			ans = append(ans, b.block(c, st, indent)...)
+		default:
+			pending = b.inline(c, st, pending)
+		}
+	}
+
+	flush()
+
+	return trimEmpty(ans)
+}
+
+// block returns the boxes of the block element n.
+func (b *boxBuilder) block(n *html.Node, st textStyle, indent float64) []box {
+	var ans []box
+
+	if isPageBreak(n, "before") {
+		ans = append(ans, breakBox{})
+	}
+
+	st = elementStyle(n, st)
+
+	switch n.DataAtom {
+	case atom.Hr:
+		ans = append(ans, &ruleBox{indent: indent})
+	case atom.Table:
+		ans = append(ans, b.table(n, st, indent))
+	case atom.Ul, atom.Ol:
+		ans = append(ans, b.list(n, st, indent)...)
+	case atom.Blockquote, atom.Dd:
+		ans = append(ans, b.blocks(n, st, indent+listIndent)...)
+	default:
+		children := b.blocks(n, st, indent)
+
+		if p, ok := firstPara(children); ok {
+			if size, ok := headingSizes[n.DataAtom]; ok {
+				p.spaceBefore = size * 0.5
+				p.spaceAfter = size * 0.3
+			} else if n.DataAtom == atom.P {
+				p.spaceAfter = st.size * 0.5
+			}
+		}
+
+		ans = append(ans, children...)
+	}
+
+	if isPageBreak(n, "after") {
+		ans = append(ans, breakBox{})
+	}
+
+	return ans
+}
+
+func (b *boxBuilder) list(n *html.Node, st textStyle, indent float64) []box {
+	var ans []box
+
+	number := 1
+
+	if start, err := strconv.Atoi(attr(n, "start")); err == nil {
+		number = start
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode || c.DataAtom != atom.Li {
+			continue
+		}
+
+		marker := "•"
+		if n.DataAtom == atom.Ol {
+			marker = strconv.Itoa(number) + "."
+		}
+
+		number++
+
+		children := b.blocks(c, elementStyle(c, st), indent+listIndent)
+
+		p, ok := firstPara(children)
+		if !ok {
+			p = &paraBox{style: st, indent: indent + listIndent}
+			children = append([]box{p}, children...)
+		}
+
+		p.marker = marker
+
+		ans = append(ans, children...)
+	}
+
+	if len(ans) > 0 {
+		if last, ok := ans[len(ans)-1].(*paraBox); ok {
+			last.spaceAfter = st.size * 0.5
+		}
+	}
+
+	return ans
+}
+
+func (b *boxBuilder) table(n *html.Node, st textStyle, indent float64) *tableBox {
+	ans := tableBox{indent: indent}
+
+	var rows func(n *html.Node, thead bool)
+
+	rows = func(n *html.Node, thead bool) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+
+			switch c.DataAtom {
+			case atom.Thead:
+				rows(c, true)
+			case atom.Tbody, atom.Tfoot:
+				rows(c, false)
+			case atom.Tr:
+				row := tableRow{thead: thead}
+
+				for cell := c.FirstChild; cell != nil; cell = cell.NextSibling {
+					if cell.Type != html.ElementNode || (cell.DataAtom != atom.Td && cell.DataAtom != atom.Th) {
+						continue
+					}
+
+					cellStyle := elementStyle(cell, st)
+					if cell.DataAtom == atom.Th {
+						cellStyle.bold = true
+					}
+
+					row.cells = append(row.cells, b.blocks(cell, cellStyle, 0))
+					row.header = append(row.header, cell.DataAtom == atom.Th)
+				}
+
+				ans.cols = max(ans.cols, len(row.cells))
+				ans.rows = append(ans.rows, row)
+			}
+		}
+	}
+
+	rows(n, false)
+
+	return &ans
+}
+
+// inline appends the runs of the inline element n to runs.
+func (b *boxBuilder) inline(n *html.Node, st textStyle, runs []run) []run {
+	if n.DataAtom == atom.Br {
+		return append(runs, run{text: "\n", style: st})
+	}
+
+	st = elementStyle(n, st)
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		switch {
+		case c.Type == html.TextNode:
+			runs = append(runs, textRuns(c.Data, st)...)
+		case c.Type != html.ElementNode || isSkipped(c.DataAtom):
+		case c.DataAtom == atom.Img:
+			b.warn("images inside text are not supported: %s", attr(c, "src"))
+		case isBlock(c.DataAtom):
+			runs = append(runs, run{text: "\n", style: st})
+			runs = b.inline(c, st, runs)
+			runs = append(runs, run{text: "\n", style: st})
+		default:
+			runs = b.inline(c, st, runs)
+		}
+	}
+
+	return runs
+}
+
+func (b *boxBuilder) image(n *html.Node, indent float64) *imageBox {
+	src := attr(n, "src")
+
+	cached, ok := b.images[src]
+	if !ok {
+		data, err := b.loadImage(src)
+		if err != nil {
+			b.warn("failed to load image at %q: %v", src, err)
+
+			return nil
+		}
+
+		decoded, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			b.warn("failed to decode image at %q: %v", src, err)
+
+			return nil
+		}
+
+		if decoded.Bounds().Empty() {
+			b.warn("image at %q is empty", src)
+
+			return nil
+		}
+
+		cached = &imageBox{img: newPDFImage(decoded)}
+
+		b.images[src] = cached
+		b.order = append(b.order, cached)
+	}
+
+	ans := *cached
+	ans.indent = indent
+	ans.width = float64(ans.img.width) * pxToPt
+	ans.height = float64(ans.img.height) * pxToPt
+	ans.align = styleProperty(n.Parent, "text-align")
+
+	w, wok := parseLength(attr(n, "width"), pxToPt)
+	h, hok := parseLength(attr(n, "height"), pxToPt)
+
+	switch {
+	case wok && hok:
+		ans.width, ans.height = w, h
+	case wok:
+		ans.height *= w / ans.width
+		ans.width = w
+	case hok:
+		ans.width *= h / ans.height
+		ans.height = h
+	}
+
+	if ans.width <= 0 || ans.height <= 0 {
+		b.warn("image at %q has no size", src)
+
+		return nil
+	}
+
+	return &ans
+}
+
+func (b *boxBuilder) loadImage(src string) ([]byte, error) {
+	if strings.HasPrefix(src, "data:") {
+		meta, data, ok := strings.Cut(src[len("data:"):], ",")
+		if !ok {
+			return nil, fmt.Errorf("invalid data URI")
+		}
+
+		if strings.HasSuffix(meta, ";base64") {
+			return base64.StdEncoding.DecodeString(data)
+		}
+
+		unescaped, err := url.PathUnescape(data)
+
+		return []byte(unescaped), err
+	}
+
+	if strings.Contains(src, "://") {
+		return nil, fmt.Errorf("remote images are not supported")
+	}
+
+	// images outside the base URL or the assets are not readable
+	name := path.Clean(strings.TrimPrefix(src, "/"))
+	if !fs.ValidPath(name) {
+		return nil, fmt.Errorf("invalid image path")
+	}
+
+	if b.opts.BaseURL != "" {
+		dir := strings.TrimPrefix(b.opts.BaseURL, "file://")
+		if strings.Contains(dir, "://") {
+			return nil, fmt.Errorf("remote base URLs are not supported")
+		}
+
+		return fs.ReadFile(os.DirFS(dir), name)
+	}
+
+	if b.opts.Assets != nil {
+		return fs.ReadFile(b.opts.Assets, name)
+	}
+
+	return nil, fmt.Errorf("no assets or base URL to resolve it")
+}
+
+func (b *boxBuilder) warn(format string, args ...any) {
+	b.diagnostics = append(b.diagnostics, Diagnostic{Level: LevelWarning, Message: fmt.Sprintf(format, args...)})
+}
+
+// pageLayout places boxes on pages.
+type pageLayout struct {
+	doc *pdfDocument
+
+	page   *pdfPage
+	y      float64
+	left   float64
+	right  float64
+	top    float64
+	bottom float64
+
+	marginTop    float64
+	marginBottom float64
+	headerHeight float64
+	footerHeight float64
+
+	diagnostics []Diagnostic
+}
+
+func (l *pageLayout) init(page PageSettings, header, footer []box) error {
+	margins := [4]float64{}
+
+	for i, s := range []string{page.Margins.Top, page.Margins.Right, page.Margins.Bottom, page.Margins.Left} {
+		margins[i] = 20 * 72 / 25.4
+
+		if s != "" {
+			v, ok := parseLength(s, 1)
+			if !ok {
+				return errorsext.WithStack(fmt.Errorf("%w: invalid margin %q", ErrInvalidPageSettings, s))
+			}
+
+			margins[i] = v
+		}
+	}
+
+	l.marginTop, l.marginBottom = margins[0], l.doc.height-margins[2]
+	l.left, l.right = margins[3], l.doc.width-margins[1]
+
+	width := l.right - l.left
+
+	l.headerHeight = l.draw(header, 0, 0, width, nil)
+	l.footerHeight = l.draw(footer, 0, 0, width, nil)
+
+	l.top = l.marginTop
+	if l.headerHeight > 0 {
+		l.top += l.headerHeight + sectionGap
+	}
+
+	l.bottom = l.marginBottom
+	if l.footerHeight > 0 {
+		l.bottom -= l.footerHeight + sectionGap
+	}
+
+	if l.bottom-l.top < defaultFontSize*lineHeightRatio {
+		return errorsext.WithStack(fmt.Errorf("%w: no space left for content", ErrInvalidPageSettings))
+	}
+
+	return nil
+}
+
+func (l *pageLayout) newPage() {
+	l.page = l.doc.addPage()
+	l.y = l.top
+}
+
+// ensure starts a new page unless height fits on the current one.
+func (l *pageLayout) ensure(height float64) bool {
+	if l.y+height > l.bottom && l.y > l.top {
+		l.newPage()
+
+		return true
+	}
+
+	return false
+}
+
+// flow places boxes on pages, starting new pages as needed.
+func (l *pageLayout) flow(boxes []box, x, width float64) {
+	for _, bx := range boxes {
+		switch v := bx.(type) {
+		case *paraBox:
+			if l.y > l.top {
+				l.y += v.spaceBefore
+			}
+
+			for i, line := range breakLines(v, width-v.indent) {
+				l.ensure(line.height)
+				l.drawLine(v, line, i == 0, x, l.y, width)
+				l.y += line.height
+			}
+
+			l.y += v.spaceAfter
+		case *imageBox:
+			w, h := fitImage(v, width-v.indent, l.bottom-l.top)
+
+			l.ensure(h)
+			l.drawImage(v, x, l.y, width, w, h)
+			l.y += h + sectionGap
+		case *ruleBox:
+			l.ensure(sectionGap * 2)
+			l.page.line(x+v.indent, l.pdfY(l.y+sectionGap), x+width, l.pdfY(l.y+sectionGap), borderWidth)
+			l.y += sectionGap * 2
+		case breakBox:
+			if l.y > l.top {
+				l.newPage()
+			}
+		case *tableBox:
+			l.flowTable(v, x, width)
+		}
+	}
+}
+
+func (l *pageLayout) flowTable(t *tableBox, x, width float64) {
+	if t.cols == 0 {
+		return
+	}
+
+	colWidth := (width - t.indent) / float64(t.cols)
+
+	var header []tableRow
+
+	for _, row := range t.rows {
+		height := l.drawRow(row, x+t.indent, 0, colWidth, nil)
+
+		if l.ensure(height) && !row.thead {
+			for _, h := range header {
+				l.y += l.drawRow(h, x+t.indent, l.y, colWidth, l.page)
+			}
+		}
+
+		if row.thead {
+			header = append(header, row)
+		}
+
+		// rows are not split, so a row taller than the page is cut off
+		if l.y+height > l.bottom {
+			l.warn("a table row is taller than the page and was clipped")
+
+			l.page.clip(x+t.indent, l.pdfY(l.bottom), width-t.indent, l.bottom-l.y)
+			l.drawRow(row, x+t.indent, l.y, colWidth, l.page)
+			l.page.restore()
+
+			l.y = l.bottom
+
+			continue
+		}
+
+		l.y += l.drawRow(row, x+t.indent, l.y, colWidth, l.page)
+	}
+
+	l.y += sectionGap
+}
+
+// drawRow draws a table row at y, or only measures it if page is nil.
+func (l *pageLayout) drawRow(row tableRow, x, y, colWidth float64, page *pdfPage) float64 {
+	var height float64
+
+	for _, cell := range row.cells {
+		height = max(height, l.draw(cell, 0, 0, colWidth-2*cellPadding, nil)+2*cellPadding)
+	}
+
+	if page == nil {
+		return height
+	}
+
+	for i, cell := range row.cells {
+		cx := x + float64(i)*colWidth
+
+		if row.header[i] {
+			page.fill(cx, l.pdfY(y+height), colWidth, height, 0.9)
+		}
+
+		l.draw(cell, cx+cellPadding, y+cellPadding, colWidth-2*cellPadding, page)
+		page.rect(cx, l.pdfY(y+height), colWidth, height, borderWidth)
+	}
+
+	return height
+}
+
+// draw draws boxes at y without page breaks and returns their height.
+// If page is nil they are only measured.
+func (l *pageLayout) draw(boxes []box, x, y, width float64, page *pdfPage) float64 {
+	start := y
+
+	saved := l.page
+	l.page = page
+
+	defer func() { l.page = saved }()
+
+	for _, bx := range boxes {
+		switch v := bx.(type) {
+		case *paraBox:
+			if y > start {
+				y += v.spaceBefore
+			}
+
+			for i, line := range breakLines(v, width-v.indent) {
+				if page != nil {
+					l.drawLine(v, line, i == 0, x, y, width)
+				}
+
+				y += line.height
+			}
+
+			y += v.spaceAfter
+		case *imageBox:
+			w, h := fitImage(v, width-v.indent, l.marginBottom-l.marginTop)
+
+			if page != nil {
+				l.drawImage(v, x, y, width, w, h)
+			}
+
+			y += h
+		case *ruleBox:
+			if page != nil {
+				page.line(x+v.indent, l.pdfY(y+sectionGap), x+width, l.pdfY(y+sectionGap), borderWidth)
+			}
+
+			y += sectionGap * 2
+		case *tableBox:
+			if v.cols == 0 {
+				continue
+			}
+
+			colWidth := (width - v.indent) / float64(v.cols)
+
+			for _, row := range v.rows {
+				y += l.drawRow(row, x+v.indent, y, colWidth, page)
+			}
+		}
+	}
+
+	return y - start
+}
+
+func (l *pageLayout) drawLine(p *paraBox, ln line, first bool, x, y, width float64) {
+	offset := x + p.indent
+
+	switch p.align {
+	case "right":
+		offset += width - p.indent - ln.width
+	case "center":
+		offset += (width - p.indent - ln.width) / 2
+	}
+
+	baseline := l.pdfY(y + ln.height*0.78)
+
+	if first && p.marker != "" {
+		marker := encodeText(p.marker)
+		l.page.text(x+p.indent-p.style.width(marker)-4, baseline, p.style.fontIndex(), p.style.size, marker)
+	}
+
+	for _, w := range ln.words {
+		l.page.text(offset+w.x, baseline, w.style.fontIndex(), w.style.size, w.text)
+	}
+}
+
+func (l *pageLayout) drawImage(img *imageBox, x, y, width, w, h float64) {
+	offset := x + img.indent
+
+	switch img.align {
+	case "right":
+		offset += width - img.indent - w
+	case "center":
+		offset += (width - img.indent - w) / 2
+	}
+
+	l.page.image(offset, l.pdfY(y+h), w, h, img.index)
+}
+
+func (l *pageLayout) warn(format string, args ...any) {
+	l.diagnostics = append(l.diagnostics, Diagnostic{Level: LevelWarning, Message: fmt.Sprintf(format, args...)})
+}
+
+// decorate draws the header and footer on every page.
+func (l *pageLayout) decorate(header, footer []box) {
+	pages := strconv.Itoa(len(l.doc.pages))
+
+	for i, page := range l.doc.pages {
+		replacer := strings.NewReplacer("{page}", strconv.Itoa(i+1), "{pages}", pages)
+
+		if len(header) > 0 {
+			l.draw(replaceTokens(header, replacer), l.left, l.marginTop, l.right-l.left, page)
+		}
+
+		if len(footer) > 0 {
+			l.draw(replaceTokens(footer, replacer), l.left, l.marginBottom-l.footerHeight, l.right-l.left, page)
+		}
+	}
+}
+
+func (l *pageLayout) pdfY(y float64) float64 {
+	return l.doc.height - y
+}
+
+func fitImage(img *imageBox, width, height float64) (w, h float64) {
+	w, h = img.width, img.height
+
+	if w > width {
+		h *= width / w
+		w = width
+	}
+
+	if h > height {
+		w *= height / h
+		h = height
+	}
+
+	return w, h
+}
+
+type placedWord struct {
+	text  string
+	style textStyle
+	x     float64
+}
+
+type line struct {
+	words  []placedWord
+	width  float64
+	height float64
+}
+
+// breakLines breaks the runs of p into lines of at most width. Words
+// longer than a line are split.
+func breakLines(p *paraBox, width float64) []line {
+	var (
+		ans          []line
+		current      line
+		pendingSpace float64
+	)
+
+	finish := func(st textStyle) {
+		if current.height == 0 {
+			current.height = st.lineHeight()
+		}
+
+		ans = append(ans, current)
+		current = line{}
+		pendingSpace = 0
+	}
+
+	add := func(text string, st textStyle, w float64) {
+		if n := len(current.words); n > 0 && pendingSpace > 0 && current.words[n-1].style == st {
+			current.words[n-1].text += " " + text
+		} else {
+			current.words = append(current.words, placedWord{text: text, style: st, x: current.width + pendingSpace})
+		}
+
+		current.width += pendingSpace + w
+		current.height = max(current.height, st.lineHeight())
+		pendingSpace = 0
+	}
+
+	for _, r := range p.runs {
+		if r.text == "\n" {
+			finish(r.style)
+
+			continue
+		}
+
+		for i, word := range strings.Split(r.text, " ") {
+			if i > 0 && len(current.words) > 0 {
+				pendingSpace = r.style.width(" ")
+			}
+
+			if word == "" {
+				continue
+			}
+
+			w := r.style.width(word)
+
+			if len(current.words) > 0 && current.width+pendingSpace+w > width {
+				finish(r.style)
+			}
+
+			// the line is empty here, split words that do not fit on it
+			for w > width && len(word) > 1 {
+				n := max(fitChars(word, r.style, width), 1)
+
+				add(word[:n], r.style, r.style.width(word[:n]))
+				finish(r.style)
+
+				word = word[n:]
+				w = r.style.width(word)
+			}
+
+			add(word, r.style, w)
+		}
+	}
+
+	if len(current.words) > 0 || (len(ans) == 0 && p.marker != "") {
+		finish(p.style)
+	}
+
+	return ans
+}
+
+func fitChars(word string, st textStyle, width float64) int {
+	for n := len(word); n > 0; n-- {
+		if st.width(word[:n]) <= width {
+			return n
+		}
+	}
+
+	return 0
+}
+
+// textRuns converts the text of a text node to runs encoded as
+// Windows-1252. Whitespace is collapsed, except in pre elements where
+// line breaks are kept.
+func textRuns(text string, st textStyle) []run {
+	if !st.pre {
+		r := run{text: encodeText(strings.Join(strings.Fields(text), " ")), style: st}
+
+		return []run{r.withSpaces(text)}
+	}
+
+	var ans []run
+
+	for i, ln := range strings.Split(text, "\n") {
+		if i > 0 {
+			ans = append(ans, run{text: "\n", style: st})
+		}
+
+		ans = append(ans, run{text: encodeText(strings.ReplaceAll(ln, "\t", "    ")), style: st})
+	}
+
+	return ans
+}
+
+// withSpaces keeps a single leading and trailing space if the original
+// text had whitespace there, since it separates it from adjacent runs.
+func (r run) withSpaces(original string) run {
+	if original == "" {
+		return r
+	}
+
+	if isSpace(original[0]) {
+		r.text = " " + r.text
+	}
+
+	if len(original) > 0 && isSpace(original[len(original)-1]) && strings.TrimSpace(original) != "" {
+		r.text += " "
+	}
+
+	return r
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+func encodeText(s string) string {
+	var sb strings.Builder
+
+	for _, r := range s {
+		if b, ok := charmap.Windows1252.EncodeRune(r); ok {
+			sb.WriteByte(b)
+		} else {
+			sb.WriteByte('?')
+		}
+	}
+
+	return sb.String()
+}
+
+func replaceTokens(boxes []box, replacer *strings.Replacer) []box {
+	ans := make([]box, len(boxes))
+
+	for i, bx := range boxes {
+		p, ok := bx.(*paraBox)
+		if !ok {
+			ans[i] = bx
+
+			continue
+		}
+
+		cp := *p
+		cp.runs = make([]run, len(p.runs))
+
+		for j, r := range p.runs {
+			cp.runs[j] = run{text: replacer.Replace(r.text), style: r.style}
+		}
+
+		ans[i] = &cp
+	}
+
+	return ans
+}
+
+// elementStyle applies the inline styles of n and its tag to st.
+func elementStyle(n *html.Node, st textStyle) textStyle {
+	switch n.DataAtom {
+	case atom.B, atom.Strong, atom.Th, atom.Dt:
+		st.bold = true
+	case atom.I, atom.Em, atom.Cite, atom.Var:
+		st.italic = true
+	case atom.Code, atom.Kbd, atom.Samp, atom.Tt:
+		st.mono = true
+	case atom.Pre:
+		st.mono = true
+		st.pre = true
+	case atom.Small:
+		st.size *= 0.85
+	}
+
+	if size, ok := headingSizes[n.DataAtom]; ok {
+		st.size = size
+		st.bold = true
+	}
+
+	switch styleProperty(n, "font-weight") {
+	case "bold", "bolder", "600", "700", "800", "900":
+		st.bold = true
+	case "normal", "400":
+		st.bold = false
+	}
+
+	switch styleProperty(n, "font-style") {
+	case "italic", "oblique":
+		st.italic = true
+	case "normal":
+		st.italic = false
+	}
+
+	if size, ok := parseLength(styleProperty(n, "font-size"), 1); ok && size > 0 {
+		st.size = size
+	}
+
+	return st
+}
+
+func isPageBreak(n *html.Node, side string) bool {
+	return styleProperty(n, "page-break-"+side) == "always" || styleProperty(n, "break-"+side) == "page"
+}
+
+// styleProperty returns the value of property in the style attribute of n.
+func styleProperty(n *html.Node, property string) string {
+	if n == nil {
+		return ""
+	}
+
+	for _, decl := range strings.Split(attr(n, "style"), ";") {
+		name, value, ok := strings.Cut(decl, ":")
+		if ok && strings.EqualFold(strings.TrimSpace(name), property) {
+			return strings.ToLower(strings.TrimSpace(value))
+		}
+	}
+
+	return ""
+}
+
+func attr(n *html.Node, name string) string {
+	for _, a := range n.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+
+	return ""
+}
+
+func findElement(n *html.Node, a atom.Atom) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == a {
+		return n
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findElement(c, a); found != nil {
+			return found
+		}
+	}
+
+	return nil
+}
+
+func firstPara(boxes []box) (*paraBox, bool) {
+	for _, bx := range boxes {
+		if p, ok := bx.(*paraBox); ok {
+			return p, true
+		}
+
+		if _, ok := bx.(breakBox); !ok {
+			return nil, false
+		}
+	}
+
+	return nil, false
+}
+
+// trimEmpty removes paragraphs that only contain whitespace.
+func trimEmpty(boxes []box) []box {
+	ans := boxes[:0]
+
+	for _, bx := range boxes {
+		if p, ok := bx.(*paraBox); ok && p.marker == "" {
+			empty := true
+
+			for _, r := range p.runs {
+				if strings.TrimSpace(r.text) != "" {
+					empty = false
+
+					break
+				}
+			}
+
+			if empty {
+				continue
+			}
+		}
+
+		ans = append(ans, bx)
+	}
+
+	return ans
+}
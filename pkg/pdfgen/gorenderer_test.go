@@ -0,0 +1,223 @@
+package pdfgen_test
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/gif"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/toolkit/pkg/pdfgen"
+)
+
+// testPNG returns a small PNG image.
+func testPNG(t *testing.T) []byte {
+	t.Helper()
+
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	img.Set(1, 1, color.NRGBA{R: 255, A: 255})
+
+	var buf bytes.Buffer
+
+	require.NoError(t, png.Encode(&buf, img))
+
+	return buf.Bytes()
+}
+
+func Test_GoRenderer(t *testing.T) {
+	t.Parallel()
+
+	dataURI := "data:image/png;base64," + base64.StdEncoding.EncodeToString(testPNG(t))
+
+	var rows strings.Builder
+
+	for i := 0; i < 80; i++ {
+		rows.WriteString("<tr><td>Item</td><td>1</td><td>9.99 €</td></tr>")
+	}
+
+	doc := `<html><head><title>Invoice</title></head><body>
+<header><p style="text-align: right">Invoice 42</p></header>
+<footer><p>Page {page} of {pages}</p></footer>
+<h1>Invoice</h1>
+<img src="` + dataURI + `" width="40">
+<p>Thank you for your <b>order</b>.</p>
+<ul><li>first</li><li>second</li></ul>
+<table><thead><tr><th>Name</th><th>Qty</th><th>Price</th></tr></thead>
+<tbody>` + rows.String() + `</tbody></table>
+<div style="page-break-before: always"><p>Terms</p></div>
+</body></html>`
+
+	var buf bytes.Buffer
+
+	result, err := pdfgen.NewGoRenderer().Render(context.Background(), &buf, strings.NewReader(doc), pdfgen.RenderOptions{})
+	require.NoError(t, err)
+	require.Empty(t, result.Diagnostics)
+
+	require.NoError(t, api.Validate(bytes.NewReader(buf.Bytes()), nil))
+
+	pages, err := api.PageCount(bytes.NewReader(buf.Bytes()), nil)
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, pages, 3)
+
+	content := pdfContent(t, buf.Bytes())
+	require.Contains(t, content, "(Invoice 42) Tj")
+	require.Contains(t, content, "(Page 1 of ")
+	require.Contains(t, content, "(Terms) Tj")
+	require.Contains(t, content, "\x80")
+	require.GreaterOrEqual(t, strings.Count(content, "(Name) Tj"), 2, "table head is repeated")
+}
+
+func Test_GoRenderer_Assets(t *testing.T) {
+	t.Parallel()
+
+	opts := pdfgen.RenderOptions{
+		Assets: fstest.MapFS{"images/logo.png": &fstest.MapFile{Data: testPNG(t)}},
+		Page:   pdfgen.PageSettings{Size: "A5", Orientation: pdfgen.Landscape, Margins: pdfgen.Margins{Top: "1cm"}},
+	}
+
+	doc := `<html><body><img src="images/logo.png"><img src="missing.png"><img src="https://example.com/a.png"></body></html>`
+
+	var buf bytes.Buffer
+
+	result, err := pdfgen.NewGoRenderer().Render(context.Background(), &buf, strings.NewReader(doc), opts)
+	require.NoError(t, err)
+	require.Len(t, result.Warnings(), 2)
+	require.NoError(t, api.Validate(bytes.NewReader(buf.Bytes()), nil))
+
+	dims, err := api.PageDims(bytes.NewReader(buf.Bytes()), nil)
+	require.NoError(t, err)
+	require.Len(t, dims, 1)
+	require.Greater(t, dims[0].Width, dims[0].Height)
+}
+
+func Test_GoRenderer_TallRow(t *testing.T) {
+	t.Parallel()
+
+	doc := `<table><tr><td>` + strings.Repeat("<p>line</p>", 200) + `</td></tr></table><p>after</p>`
+
+	var buf bytes.Buffer
+
+	result, err := pdfgen.NewGoRenderer().Render(context.Background(), &buf, strings.NewReader(doc), pdfgen.RenderOptions{})
+	require.NoError(t, err)
+	require.Len(t, result.Warnings(), 1)
+	require.NoError(t, api.Validate(bytes.NewReader(buf.Bytes()), nil))
+
+	content := pdfContent(t, buf.Bytes())
+	require.Contains(t, content, "re W n")
+	require.Contains(t, content, "(after) Tj")
+}
+
+func Test_GoRenderer_EmptyImage(t *testing.T) {
+	t.Parallel()
+
+	var empty bytes.Buffer
+
+	require.NoError(t, gif.Encode(&empty, image.NewPaletted(image.Rect(0, 0, 0, 0), palette.Plan9), nil))
+
+	opts := pdfgen.RenderOptions{
+		Assets: fstest.MapFS{
+			"empty.gif": &fstest.MapFile{Data: empty.Bytes()},
+			"logo.png":  &fstest.MapFile{Data: testPNG(t)},
+		},
+	}
+
+	doc := `<img src="empty.gif" width="10"><img src="logo.png" width="0"><img src="logo.png" height="0">`
+
+	var buf bytes.Buffer
+
+	result, err := pdfgen.NewGoRenderer().Render(context.Background(), &buf, strings.NewReader(doc), opts)
+	require.NoError(t, err)
+	require.Len(t, result.Warnings(), 3)
+	require.NoError(t, api.Validate(bytes.NewReader(buf.Bytes()), nil))
+	require.NotContains(t, pdfContent(t, buf.Bytes()), "NaN")
+}
+
+func Test_GoRenderer_ImageTraversal(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	base := filepath.Join(dir, "templates")
+
+	require.NoError(t, os.Mkdir(base, 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "secret.png"), testPNG(t), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(base, "logo.png"), testPNG(t), 0o600))
+
+	doc := `<img src="logo.png"><img src="../secret.png"><img src="/../secret.png"><img src="a/../../secret.png">`
+
+	var buf bytes.Buffer
+
+	result, err := pdfgen.NewGoRenderer().Render(context.Background(), &buf, strings.NewReader(doc), pdfgen.RenderOptions{BaseURL: base})
+	require.NoError(t, err)
+	require.Len(t, result.Warnings(), 3)
+
+	for _, w := range result.Warnings() {
+		require.Contains(t, w.Message, "invalid image path")
+	}
+}
+
+func Test_GoRenderer_InvalidPageSize(t *testing.T) {
+	t.Parallel()
+
+	opts := pdfgen.RenderOptions{Page: pdfgen.PageSettings{Size: "huge"}}
+
+	_, err := pdfgen.NewGoRenderer().Render(context.Background(), io.Discard, strings.NewReader("<p>x</p>"), opts)
+	require.ErrorIs(t, err, pdfgen.ErrInvalidPageSettings)
+}
+
+func Test_NewFallbackRenderer(t *testing.T) {
+	t.Parallel()
+
+	missing, err := pdfgen.NewWeasyPrint(pdfgen.WithBinary("does-not-exist"))
+	require.NoError(t, err)
+
+	r := pdfgen.NewFallbackRenderer(missing, pdfgen.NewGoRenderer())
+
+	var buf bytes.Buffer
+
+	_, err = r.Render(context.Background(), &buf, strings.NewReader("<p>hello</p>"), pdfgen.RenderOptions{})
+	require.NoError(t, err)
+	require.NoError(t, api.Validate(bytes.NewReader(buf.Bytes()), nil))
+
+	_, err = pdfgen.NewFallbackRenderer(missing).Render(context.Background(), &buf, strings.NewReader(""), pdfgen.RenderOptions{})
+	require.ErrorIs(t, err, pdfgen.ErrBinaryNotFound)
+}
+
+// pdfContent returns the decompressed streams of pdf.
+func pdfContent(t *testing.T, pdf []byte) string {
+	t.Helper()
+
+	var ans strings.Builder
+
+	for rest := pdf; ; {
+		_, after, ok := bytes.Cut(rest, []byte("stream\n"))
+		if !ok {
+			break
+		}
+
+		data, next, _ := bytes.Cut(after, []byte("\nendstream"))
+		rest = next
+
+		zr, err := zlib.NewReader(bytes.NewReader(data))
+		require.NoError(t, err)
+
+		b, err := io.ReadAll(zr)
+		require.NoError(t, err)
+
+		ans.Write(b)
+	}
+
+	return ans.String()
+}
@@ -0,0 +1,175 @@
+package pdfgen
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"path"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+
+	"github.com/gosom/toolkit/pkg/errorsext"
+)
+
+var (
+	ErrUnsupportedVariant = errors.New("pdf variant is not supported by the renderer")
+	ErrInvalidPDF         = errors.New("invalid pdf")
+	ErrInvalidAttachment  = errors.New("invalid attachment")
+)
+
+// Variant is a PDF standard the output conforms to.
+type Variant string
+
+const (
+	// PDFA3b is PDF/A-3b for archiving. Unlike PDF/A-2 it allows
+	// attachments.
+	PDFA3b Variant = "pdf/a-3b"
+	// PDFUA1 is PDF/UA-1, a tagged PDF for accessibility.
+	PDFUA1 Variant = "pdf/ua-1"
+)
+
+// Metadata is stored in the document information of the PDF.
+type Metadata struct {
+	Title    string
+	Author   string
+	Subject  string
+	Keywords []string
+}
+
+func (m Metadata) isZero() bool {
+	return m.Title == "" && m.Author == "" && m.Subject == "" && len(m.Keywords) == 0
+}
+
+// info returns the entries of the document information dictionary.
+func (m Metadata) info() [][2]string {
+	var ans [][2]string
+
+	for _, e := range [][2]string{
+		{"Title", m.Title},
+		{"Author", m.Author},
+		{"Subject", m.Subject},
+		{"Keywords", strings.Join(m.Keywords, ", ")},
+	} {
+		if e[1] != "" {
+			ans = append(ans, e)
+		}
+	}
+
+	return ans
+}
+
+// html returns the title and meta elements for the metadata.
+func (m Metadata) html() string {
+	var b strings.Builder
+
+	if m.Title != "" {
+		b.WriteString("<title>" + html.EscapeString(m.Title) + "</title>")
+	}
+
+	for _, e := range [][2]string{
+		{"author", m.Author},
+		{"description", m.Subject},
+		{"keywords", strings.Join(m.Keywords, ", ")},
+	} {
+		if e[1] != "" {
+			b.WriteString(`<meta name="` + e[0] + `" content="` + html.EscapeString(e[1]) + `">`)
+		}
+	}
+
+	return b.String()
+}
+
+// Attachment is a file embedded in the PDF, such as the XML version of
+// an invoice.
+type Attachment struct {
+	// Name is the file name, e.g. invoice.xml.
+	Name        string
+	Data        []byte
+	Description string
+}
+
+func validateAttachments(attachments []Attachment) error {
+	seen := make(map[string]bool, len(attachments))
+
+	for _, a := range attachments {
+		if a.Name == "" || a.Name == "." || a.Name == ".." || a.Name != path.Base(a.Name) || strings.ContainsAny(a.Name, `/\`) {
+			return errorsext.WithStack(fmt.Errorf("%w: invalid name %q", ErrInvalidAttachment, a.Name))
+		}
+
+		if seen[a.Name] {
+			return errorsext.WithStack(fmt.Errorf("%w: duplicate name %q", ErrInvalidAttachment, a.Name))
+		}
+
+		seen[a.Name] = true
+	}
+
+	return nil
+}
+
+// ValidatePDF validates the structure of pdf with pdfcpu. It does not
+// check the conformance to a Variant.
+func ValidatePDF(pdf io.ReadSeeker) error {
+	if err := api.Validate(pdf, nil); err != nil {
+		return errorsext.WithStack(fmt.Errorf("%w: %w", ErrInvalidPDF, err))
+	}
+
+	return nil
+}
+
+// addMetadata adds the metadata and the attachments to pdf with pdfcpu,
+// for renderers that cannot add them themselves.
+func addMetadata(w io.Writer, pdf io.ReadSeeker, m Metadata, attachments []Attachment) error {
+	ctx, err := api.ReadValidateAndOptimize(pdf, model.NewDefaultConfiguration())
+	if err != nil {
+		return errorsext.WithStack(err)
+	}
+
+	if entries := m.info(); len(entries) > 0 {
+		if ctx.Info == nil {
+			ctx.Info, err = ctx.IndRefForNewObject(types.NewDict())
+			if err != nil {
+				return errorsext.WithStack(err)
+			}
+		}
+
+		d, err := ctx.DereferenceDict(*ctx.Info)
+		if err != nil {
+			return errorsext.WithStack(err)
+		}
+
+		for _, e := range entries {
+			d.Update(e[0], types.NewHexLiteral(utf16BE(e[1])))
+		}
+	}
+
+	for _, a := range attachments {
+		err := ctx.AddAttachment(model.Attachment{
+			Reader:   bytes.NewReader(a.Data),
+			ID:       a.Name,
+			FileName: a.Name,
+			Desc:     a.Description,
+		}, false)
+		if err != nil {
+			return errorsext.WithStack(err)
+		}
+	}
+
+	return errorsext.WithStack(api.WriteContext(ctx, w))
+}
+
+// utf16BE encodes s as a PDF text string in UTF-16BE with a byte order mark.
+func utf16BE(s string) []byte {
+	ans := []byte{0xfe, 0xff}
+
+	for _, r := range utf16.Encode([]rune(s)) {
+		ans = append(ans, byte(r>>8), byte(r))
+	}
+
+	return ans
+}
@@ -0,0 +1,160 @@
+package pdfgen_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/toolkit/pkg/pdfgen"
+)
+
+var invoiceOptions = pdfgen.RenderOptions{
+	Metadata: pdfgen.Metadata{
+		Title:    "Rechnung Müller",
+		Author:   "ACME GmbH",
+		Subject:  "Invoice 42",
+		Keywords: []string{"invoice", "2024"},
+	},
+	Attachments: []pdfgen.Attachment{{
+		Name:        "invoice.xml",
+		Data:        []byte("<rsm:CrossIndustryInvoice/>"),
+		Description: "XML invoice",
+	}},
+	Validate: true,
+}
+
+// requireInvoice checks the metadata and the attachment of invoiceOptions.
+func requireInvoice(t *testing.T, pdf []byte) {
+	t.Helper()
+
+	ctx, err := api.ReadContext(bytes.NewReader(pdf), nil)
+	require.NoError(t, err)
+	require.NotNil(t, ctx.Info)
+
+	info, err := ctx.DereferenceDict(*ctx.Info)
+	require.NoError(t, err)
+
+	for key, want := range map[string]string{
+		"Title":    "Rechnung Müller",
+		"Author":   "ACME GmbH",
+		"Subject":  "Invoice 42",
+		"Keywords": "invoice, 2024",
+	} {
+		got, err := ctx.DereferenceText(info[key])
+		require.NoError(t, err)
+		require.Equal(t, want, got, key)
+	}
+
+	attachments, err := api.Attachments(bytes.NewReader(pdf), nil)
+	require.NoError(t, err)
+	require.Len(t, attachments, 1)
+	require.Equal(t, "invoice.xml", attachments[0].FileName)
+	require.Equal(t, "XML invoice", attachments[0].Desc)
+}
+
+func Test_GoRenderer_Metadata(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	_, err := pdfgen.NewGoRenderer().Render(context.Background(), &buf, strings.NewReader("<h1>Invoice</h1>"), invoiceOptions)
+	require.NoError(t, err)
+
+	requireInvoice(t, buf.Bytes())
+}
+
+func Test_CommandRenderer_Metadata(t *testing.T) {
+	t.Parallel()
+
+	var fixture bytes.Buffer
+
+	_, err := pdfgen.NewGoRenderer().Render(context.Background(), &fixture, strings.NewReader("<h1>Invoice</h1>"), pdfgen.RenderOptions{})
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "fixture.pdf")
+	require.NoError(t, os.WriteFile(path, fixture.Bytes(), 0o600))
+
+	// wkhtmltopdf does not read the metadata from the document, so it is
+	// added to its output afterwards
+	r, err := pdfgen.NewWkhtmltopdf(pdfgen.WithBinary(scriptBinary(t, `cat "`+path+`"
+`)))
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+
+	_, err = r.Render(context.Background(), &buf, strings.NewReader("<h1>Invoice</h1>"), invoiceOptions)
+	require.NoError(t, err)
+
+	requireInvoice(t, buf.Bytes())
+}
+
+func Test_CommandRenderer_Variant(t *testing.T) {
+	t.Parallel()
+
+	r, err := pdfgen.NewWeasyPrint(pdfgen.WithBinary(fakeBinary(t)))
+	require.NoError(t, err)
+
+	opts := invoiceOptions
+	opts.Variant = pdfgen.PDFA3b
+	opts.Validate = false
+
+	var buf bytes.Buffer
+
+	_, err = r.Render(context.Background(), &buf, strings.NewReader("<html><head></head><body></body></html>"), opts)
+	require.NoError(t, err)
+
+	out := buf.String()
+	require.Contains(t, out, "--pdf-variant pdf/a-3b --attachment ")
+	require.Contains(t, out, "/invoice.xml ")
+	require.Contains(t, out, `<head><title>Rechnung Müller</title><meta name="author" content="ACME GmbH">`+
+		`<meta name="description" content="Invoice 42"><meta name="keywords" content="invoice, 2024"></head>`)
+
+	wkhtmltopdf, err := pdfgen.NewWkhtmltopdf(pdfgen.WithBinary(fakeBinary(t)))
+	require.NoError(t, err)
+
+	_, err = wkhtmltopdf.Render(context.Background(), io.Discard, strings.NewReader(""), opts)
+	require.ErrorIs(t, err, pdfgen.ErrUnsupportedVariant)
+
+	_, err = pdfgen.NewGoRenderer().Render(context.Background(), io.Discard, strings.NewReader(""), opts)
+	require.ErrorIs(t, err, pdfgen.ErrUnsupportedVariant)
+
+	_, err = pdfgen.NewFallbackRenderer(wkhtmltopdf, r).Render(context.Background(), &buf, strings.NewReader(""), opts)
+	require.NoError(t, err)
+}
+
+func Test_Render_Validate(t *testing.T) {
+	t.Parallel()
+
+	r, err := pdfgen.NewWeasyPrint(pdfgen.WithBinary(fakeBinary(t)))
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+
+	_, err = r.Render(context.Background(), &buf, strings.NewReader("<h1>test</h1>"), pdfgen.RenderOptions{Validate: true})
+	require.ErrorIs(t, err, pdfgen.ErrInvalidPDF)
+	require.Empty(t, buf.Bytes())
+
+	require.ErrorIs(t, pdfgen.ValidatePDF(strings.NewReader("%PDF-1.7")), pdfgen.ErrInvalidPDF)
+}
+
+func Test_Render_InvalidAttachment(t *testing.T) {
+	t.Parallel()
+
+	for _, attachments := range [][]pdfgen.Attachment{
+		{{Name: "../invoice.xml"}},
+		{{Name: ""}},
+		{{Name: "."}},
+		{{Name: ".."}},
+		{{Name: "a.xml"}, {Name: "a.xml"}},
+	} {
+		_, err := pdfgen.NewGoRenderer().Render(context.Background(), io.Discard, strings.NewReader(""), pdfgen.RenderOptions{Attachments: attachments})
+		require.ErrorIs(t, err, pdfgen.ErrInvalidAttachment)
+	}
+}
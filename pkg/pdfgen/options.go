@@ -0,0 +1,359 @@
+package pdfgen
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gosom/toolkit/pkg/errorsext"
+)
+
+var (
+	ErrInvalidPageSettings  = errors.New("invalid page settings")
+	ErrUnsupportedMediaType = errors.New("media type is not supported by the renderer")
+)
+
+type Orientation string
+
+const (
+	Portrait  Orientation = "portrait"
+	Landscape Orientation = "landscape"
+)
+
+// Margins are CSS lengths such as "20mm" or "1in".
+type Margins struct {
+	Top    string
+	Right  string
+	Bottom string
+	Left   string
+}
+
+// PageSettings configures the printed page. Empty fields keep the defaults
+// of the renderer.
+type PageSettings struct {
+	// Size is a named size such as A4 or Letter, or a width and a height
+	// such as "100mm 150mm".
+	Size        string
+	Orientation Orientation
+	Margins     Margins
+	// MediaType is the CSS media type, print or screen. Chromium always
+	// renders with print and fails with ErrUnsupportedMediaType for any
+	// other media type.
+	MediaType string
+}
+
+// RenderOptions configures how a single document is rendered.
+type RenderOptions struct {
+	// BaseURL is the URL or local directory relative URLs of the document
+	// are resolved against. It takes precedence over Assets.
+	BaseURL string
+	// Assets such as stylesheets, fonts and images are written next to the
+	// document, so relative URLs in the document resolve to them.
+	Assets fs.FS
+	// Stylesheets are CSS sources added to the document.
+	Stylesheets []string
+	Page        PageSettings
+	Metadata    Metadata
+	// Variant requests a PDF standard. Renderers that cannot produce it
+	// fail with ErrUnsupportedVariant.
+	Variant     Variant
+	Attachments []Attachment
+	// Validate validates the output with ValidatePDF before it is written.
+	Validate bool
+}
+
+// pageCSS returns the @page rule for the page settings.
+func (p PageSettings) pageCSS() string {
+	var rules []string
+
+	size := strings.TrimSpace(p.Size + " " + string(p.Orientation))
+	if size != "" {
+		rules = append(rules, "size: "+size+";")
+	}
+
+	m := p.Margins
+	if m != (Margins{}) {
+		rules = append(rules, "margin: "+cssLength(m.Top)+" "+cssLength(m.Right)+" "+cssLength(m.Bottom)+" "+cssLength(m.Left)+";")
+	}
+
+	if len(rules) == 0 {
+		return ""
+	}
+
+	return "@page { " + strings.Join(rules, " ") + " }"
+}
+
+func cssLength(s string) string {
+	if s == "" {
+		return "0"
+	}
+
+	return s
+}
+
+// headPrefixLimit bounds how much of a document is read to find its head.
+const headPrefixLimit = 64 << 10
+
+// prepareHTML adds the metadata, the base URL and the stylesheets to the
+// head of the document, which is added if it has none. A title of the
+// document is kept. With pageCSS the page settings are added as an @page
+// rule. Only the start of the document up to the body is read.
+func prepareHTML(doc io.Reader, opts RenderOptions, pageCSS bool) (io.Reader, error) {
+	var tags strings.Builder
+
+	if opts.BaseURL != "" {
+		baseURL, err := resolveBaseURL(opts.BaseURL)
+		if err != nil {
+			return nil, err
+		}
+
+		tags.WriteString(`<base href="` + html.EscapeString(baseURL) + `">`)
+	}
+
+	css := opts.Stylesheets
+
+	if pageCSS {
+		if rule := opts.Page.pageCSS(); rule != "" {
+			css = append([]string{rule}, css...)
+		}
+	}
+
+	for _, s := range css {
+		tags.WriteString("<style>" + s + "</style>")
+	}
+
+	if tags.Len() == 0 && opts.Metadata.isZero() {
+		return doc, nil
+	}
+
+	prefix, err := readHead(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	meta := opts.Metadata
+	if bytes.Contains(headSection(prefix), []byte("<title")) {
+		meta.Title = ""
+	}
+
+	head := meta.html() + tags.String()
+	if head == "" {
+		return io.MultiReader(bytes.NewReader(prefix), doc), nil
+	}
+
+	pos := headEnd(prefix)
+	if pos == 0 {
+		// a head before the doctype would switch to quirks mode
+		pos = headStart(prefix)
+		head = "<head>" + head + "</head>"
+	}
+
+	return io.MultiReader(bytes.NewReader(prefix[:pos]), strings.NewReader(head), bytes.NewReader(prefix[pos:]), doc), nil
+}
+
+// readHead reads doc until the end of the head, the body or
+// headPrefixLimit bytes.
+func readHead(doc io.Reader) ([]byte, error) {
+	var (
+		prefix []byte
+		buf    = make([]byte, 4096)
+	)
+
+	for len(prefix) < headPrefixLimit {
+		n, err := doc.Read(buf)
+		prefix = append(prefix, buf[:n]...)
+
+		if len(headSection(prefix)) < len(prefix) {
+			break
+		}
+
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, errorsext.WithStack(err)
+		}
+	}
+
+	return prefix, nil
+}
+
+// headSection returns the lowercased start of doc before the end of the
+// head or the body.
+func headSection(doc []byte) []byte {
+	lower := bytes.ToLower(doc)
+
+	for _, tag := range []string{"</head", "<body"} {
+		if i := bytes.Index(lower, []byte(tag)); i >= 0 {
+			lower = lower[:i]
+		}
+	}
+
+	return lower
+}
+
+// headEnd returns the position right after the opening head tag, or 0 if
+// the document has none.
+func headEnd(doc []byte) int {
+	lower := headSection(doc)
+
+	for offset := 0; ; {
+		i := bytes.Index(lower[offset:], []byte("<head"))
+		if i < 0 {
+			return 0
+		}
+
+		i += offset
+		end := i + len("<head")
+
+		// skip <header>
+		if end < len(lower) && (lower[end] == '>' || lower[end] == ' ' || lower[end] == '\t' || lower[end] == '\n') {
+			closing := bytes.IndexByte(lower[end:], '>')
+			if closing < 0 {
+				return 0
+			}
+
+			return end + closing + 1
+		}
+
+		offset = end
+	}
+}
+
+// headStart returns the position of a head added to a document without
+// one: after the opening html tag or the doctype, or 0 if it has neither.
+func headStart(doc []byte) int {
+	lower := headSection(doc)
+
+	for _, tag := range []string{"<html", "<!doctype"} {
+		i := bytes.Index(lower, []byte(tag))
+		if i < 0 {
+			continue
+		}
+
+		if closing := bytes.IndexByte(lower[i:], '>'); closing >= 0 {
+			return i + closing + 1
+		}
+	}
+
+	return 0
+}
+
+func resolveBaseURL(base string) (string, error) {
+	if strings.Contains(base, "://") {
+		return base, nil
+	}
+
+	abs, err := filepath.Abs(base)
+	if err != nil {
+		return "", errorsext.WithStack(err)
+	}
+
+	return "file://" + filepath.ToSlash(abs) + "/", nil
+}
+
+// writeAssets copies the files of assets into dir.
+func writeAssets(dir string, assets fs.FS) error {
+	return fs.WalkDir(assets, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return errorsext.WithStack(err)
+		}
+
+		target := filepath.Join(dir, filepath.FromSlash(path))
+
+		if d.IsDir() {
+			return errorsext.WithStack(os.MkdirAll(target, 0o700))
+		}
+
+		src, err := assets.Open(path)
+		if err != nil {
+			return errorsext.WithStack(err)
+		}
+
+		defer src.Close()
+
+		return writeFile(target, src)
+	})
+}
+
+// pageSizes are the named page sizes in points.
+var pageSizes = map[string][2]float64{
+	"a3":     {841.89, 1190.55},
+	"a4":     {595.28, 841.89},
+	"a5":     {419.53, 595.28},
+	"a6":     {297.64, 419.53},
+	"letter": {612, 792},
+	"legal":  {612, 1008},
+}
+
+// pageSize returns the page width and height in points. It defaults to A4.
+func pageSize(p PageSettings) (width, height float64, err error) {
+	size := strings.ToLower(strings.TrimSpace(p.Size))
+
+	switch named, ok := pageSizes[size]; {
+	case size == "":
+		width, height = pageSizes["a4"][0], pageSizes["a4"][1]
+	case ok:
+		width, height = named[0], named[1]
+	default:
+		w, h, _ := strings.Cut(size, " ")
+
+		var wok, hok bool
+
+		width, wok = parseLength(w, 1)
+		height, hok = parseLength(strings.TrimSpace(h), 1)
+
+		if !wok || !hok || width <= 0 || height <= 0 {
+			return 0, 0, errorsext.WithStack(fmt.Errorf("%w: invalid page size %q", ErrInvalidPageSettings, p.Size))
+		}
+	}
+
+	if (p.Orientation == Landscape) != (width > height) && p.Orientation != "" {
+		width, height = height, width
+	}
+
+	return width, height, nil
+}
+
+// parseLength converts a CSS length to points. Numbers without a unit
+// are multiplied by unitless.
+func parseLength(s string, unitless float64) (float64, bool) {
+	s = strings.TrimSpace(strings.ToLower(s))
+
+	units := []struct {
+		suffix string
+		factor float64
+	}{
+		{"mm", 72 / 25.4},
+		{"cm", 72 / 2.54},
+		{"in", 72},
+		{"pt", 1},
+		{"px", 0.75},
+	}
+
+	factor := unitless
+
+	for _, u := range units {
+		if strings.HasSuffix(s, u.suffix) {
+			s = strings.TrimSpace(strings.TrimSuffix(s, u.suffix))
+			factor = u.factor
+
+			break
+		}
+	}
+
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return v * factor, true
+}
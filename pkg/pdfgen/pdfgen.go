@@ -1,61 +1,79 @@
 package pdfgen
 
 import (
+	"bytes"
 	"context"
 	"io"
-	"os"
-	"os/exec"
+	"sync"
 
 	"github.com/gosom/toolkit/pkg/errorsext"
 )
 
-func Generate(ctx context.Context, w io.Writer, html []byte) error {
-	tempHTMLFile, err := os.CreateTemp("", "*.html")
-	if err != nil {
-		return errorsext.WithStack(err)
-	}
+var (
+	defaultMu       sync.RWMutex
+	defaultRenderer Renderer
 
-	defer os.Remove(tempHTMLFile.Name())
+	// newDefaultRenderer creates the default renderer on first use, so
+	// importing the package has no side effects.
+	newDefaultRenderer = sync.OnceValues(func() (Renderer, error) {
+		weasyprint, err := NewWeasyPrint()
+		if err != nil {
+			return nil, err
+		}
 
-	if _, err = tempHTMLFile.Write(html); err != nil {
-		return errorsext.WithStack(err)
-	}
+		return NewPool(weasyprint)
+	})
+)
 
-	if err = tempHTMLFile.Close(); err != nil {
-		return errorsext.WithStack(err)
-	}
+// SetDefaultRenderer sets the renderer used by Generate and its variants,
+// typically a Pool. It defaults to a Pool rendering with WeasyPrint with
+// the default concurrency.
+func SetDefaultRenderer(r Renderer) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
 
-	tempPDFFile, err := os.CreateTemp("", "*.pdf")
-	if err != nil {
-		return errorsext.WithStack(err)
-	}
+	defaultRenderer = r
+}
 
-	tempPDFFilePath := tempPDFFile.Name()
-	defer os.Remove(tempPDFFilePath)
+// DefaultRenderer returns the renderer used by Generate. It fails if the
+// default renderer cannot be created.
+func DefaultRenderer() (Renderer, error) {
+	defaultMu.RLock()
+	r := defaultRenderer
+	defaultMu.RUnlock()
 
-	if err = tempPDFFile.Close(); err != nil {
-		return errorsext.WithStack(err)
+	if r != nil {
+		return r, nil
 	}
 
-	//nolint:gosec // weasyprint is a trusted binary
-	cmd := exec.CommandContext(ctx, "weasyprint", tempHTMLFile.Name(), tempPDFFilePath)
-
-	err = cmd.Run()
-
+	r, err := newDefaultRenderer()
 	if err != nil {
-		return errorsext.WithStack(err)
+		return nil, errorsext.WithStack(err)
 	}
 
-	pdfFile, err := os.Open(tempPDFFilePath)
-	if err != nil {
-		return errorsext.WithStack(err)
-	}
+	return r, nil
+}
+
+// Generate renders html to PDF with the default renderer.
+// Use SetDefaultRenderer or a Renderer to pick another engine.
+func Generate(ctx context.Context, w io.Writer, html []byte) error {
+	return GenerateWithOptions(ctx, w, html, RenderOptions{})
+}
 
-	defer pdfFile.Close()
+// GenerateWithOptions is like Generate with RenderOptions.
+func GenerateWithOptions(ctx context.Context, w io.Writer, html []byte, opts RenderOptions) error {
+	return GenerateFromReader(ctx, w, bytes.NewReader(html), opts)
+}
 
-	if _, err = io.Copy(w, pdfFile); err != nil {
-		return errorsext.WithStack(err)
+// GenerateFromReader is like GenerateWithOptions, but streams the
+// document from html instead of holding it in memory.
+func GenerateFromReader(ctx context.Context, w io.Writer, html io.Reader, opts RenderOptions) error {
+	r, err := DefaultRenderer()
+	if err != nil {
+		return err
 	}
 
-	return nil
+	_, err = r.Render(ctx, w, html, opts)
+
+	return err
 }
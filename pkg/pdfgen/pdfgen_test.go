@@ -3,6 +3,7 @@ package pdfgen_test
 import (
 	"bytes"
 	"context"
+	"io"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -23,3 +24,26 @@ func Test_Generate(t *testing.T) {
 
 	require.NotEmpty(t, buff.Bytes())
 }
+
+func Test_SetDefaultRenderer(t *testing.T) {
+	previous, err := pdfgen.DefaultRenderer()
+	require.NoError(t, err)
+	require.IsType(t, &pdfgen.Pool{}, previous)
+
+	defer pdfgen.SetDefaultRenderer(previous)
+
+	pool, err := pdfgen.NewPool(pdfgen.RendererFunc(func(_ context.Context, w io.Writer, html io.Reader, _ pdfgen.RenderOptions) (*pdfgen.Result, error) {
+		_, err := io.Copy(w, html)
+
+		return &pdfgen.Result{}, err
+	}))
+	require.NoError(t, err)
+
+	pdfgen.SetDefaultRenderer(pool)
+
+	buff := bytes.NewBuffer(nil)
+
+	require.NoError(t, pdfgen.Generate(context.Background(), buff, []byte("<h1>test</h1>")))
+	require.Equal(t, "<h1>test</h1>", buff.String())
+	require.Equal(t, uint64(1), pool.Stats().Completed)
+}
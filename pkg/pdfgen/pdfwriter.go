@@ -0,0 +1,310 @@
+package pdfgen
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gosom/toolkit/pkg/errorsext"
+)
+
+// pdfFonts are the standard fonts used by GoRenderer. They are not
+// embedded, every PDF reader provides them.
+var pdfFonts = []string{
+	"Helvetica",
+	"Helvetica-Bold",
+	"Helvetica-Oblique",
+	"Helvetica-BoldOblique",
+	"Courier",
+	"Courier-Bold",
+}
+
+// pdfImage is an image XObject with 8 bit RGB samples.
+type pdfImage struct {
+	width  int
+	height int
+	rgb    []byte
+}
+
+func newPDFImage(img image.Image) *pdfImage {
+	bounds := img.Bounds()
+
+	ans := pdfImage{
+		width:  bounds.Dx(),
+		height: bounds.Dy(),
+		rgb:    make([]byte, 0, bounds.Dx()*bounds.Dy()*3),
+	}
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			// composite transparent pixels over white
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			a := uint32(c.A)
+
+			ans.rgb = append(ans.rgb,
+				byte((uint32(c.R)*a+255*(255-a))/255),
+				byte((uint32(c.G)*a+255*(255-a))/255),
+				byte((uint32(c.B)*a+255*(255-a))/255),
+			)
+		}
+	}
+
+	return &ans
+}
+
+// pdfPage is the content stream of a page.
+type pdfPage struct {
+	content bytes.Buffer
+}
+
+func (p *pdfPage) text(x, y float64, fontIndex int, size float64, text string) {
+	fmt.Fprintf(&p.content, "BT /F%d %s Tf %s %s Td (%s) Tj ET\n",
+		fontIndex+1, pdfNumber(size), pdfNumber(x), pdfNumber(y), pdfEscape(text))
+}
+
+func (p *pdfPage) line(x1, y1, x2, y2, width float64) {
+	fmt.Fprintf(&p.content, "%s w %s %s m %s %s l S\n",
+		pdfNumber(width), pdfNumber(x1), pdfNumber(y1), pdfNumber(x2), pdfNumber(y2))
+}
+
+func (p *pdfPage) rect(x, y, w, h, width float64) {
+	fmt.Fprintf(&p.content, "%s w %s %s %s %s re S\n",
+		pdfNumber(width), pdfNumber(x), pdfNumber(y), pdfNumber(w), pdfNumber(h))
+}
+
+func (p *pdfPage) fill(x, y, w, h, gray float64) {
+	fmt.Fprintf(&p.content, "%s g %s %s %s %s re f 0 g\n",
+		pdfNumber(gray), pdfNumber(x), pdfNumber(y), pdfNumber(w), pdfNumber(h))
+}
+
+// clip restricts drawing to the rectangle until restore is called.
+func (p *pdfPage) clip(x, y, w, h float64) {
+	fmt.Fprintf(&p.content, "q %s %s %s %s re W n\n",
+		pdfNumber(x), pdfNumber(y), pdfNumber(w), pdfNumber(h))
+}
+
+func (p *pdfPage) restore() {
+	p.content.WriteString("Q\n")
+}
+
+func (p *pdfPage) image(x, y, w, h float64, imageIndex int) {
+	fmt.Fprintf(&p.content, "q %s 0 0 %s %s %s cm /Im%d Do Q\n",
+		pdfNumber(w), pdfNumber(h), pdfNumber(x), pdfNumber(y), imageIndex+1)
+}
+
+// pdfDocument is a minimal PDF writer for GoRenderer.
+type pdfDocument struct {
+	width  float64
+	height float64
+	pages  []*pdfPage
+	images []*pdfImage
+	// info are the entries of the document information dictionary.
+	info        [][2]string
+	attachments []Attachment
+}
+
+func (d *pdfDocument) addPage() *pdfPage {
+	ans := &pdfPage{}
+
+	d.pages = append(d.pages, ans)
+
+	return ans
+}
+
+func (d *pdfDocument) addImage(img *pdfImage) int {
+	d.images = append(d.images, img)
+
+	return len(d.images) - 1
+}
+
+// write writes the document. The objects are laid out as catalog, page
+// tree, fonts, images, a page and its content stream per page, the
+// document information and a file and its file specification per
+// attachment.
+func (d *pdfDocument) write(w io.Writer) error {
+	var (
+		buf     bytes.Buffer
+		offsets []int
+	)
+
+	const (
+		catalogObj = 1
+		pagesObj   = 2
+		firstFont  = 3
+	)
+
+	firstImage := firstFont + len(pdfFonts)
+	firstPage := firstImage + len(d.images)
+	infoObj := firstPage + 2*len(d.pages)
+	firstAttachment := infoObj
+
+	if len(d.info) > 0 {
+		firstAttachment++
+	}
+
+	// the names of the embedded files must be sorted
+	attachments := slices.Clone(d.attachments)
+	slices.SortFunc(attachments, func(a, b Attachment) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	object := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	stream := func(dict string, data []byte) error {
+		var compressed bytes.Buffer
+
+		zw := zlib.NewWriter(&compressed)
+
+		if _, err := zw.Write(data); err != nil {
+			return errorsext.WithStack(err)
+		}
+
+		if err := zw.Close(); err != nil {
+			return errorsext.WithStack(err)
+		}
+
+		object(fmt.Sprintf("<< %s /Filter /FlateDecode /Length %d >>\nstream\n%s\nendstream",
+			dict, compressed.Len(), compressed.Bytes()))
+
+		return nil
+	}
+
+	buf.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+
+	var names []string
+
+	for i, a := range attachments {
+		names = append(names, fmt.Sprintf("(%s) %d 0 R", pdfEscape(a.Name), firstAttachment+2*i+1))
+	}
+
+	if len(names) > 0 {
+		object(fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R /Names << /EmbeddedFiles << /Names [%s] >> >> >>",
+			pagesObj, strings.Join(names, " ")))
+	} else {
+		object(fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pagesObj))
+	}
+
+	var (
+		kids      []string
+		fonts     []string
+		xobjects  []string
+		mediaBox  = fmt.Sprintf("[0 0 %s %s]", pdfNumber(d.width), pdfNumber(d.height))
+		resources string
+	)
+
+	for i := range d.pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", firstPage+2*i))
+	}
+
+	for i := range pdfFonts {
+		fonts = append(fonts, fmt.Sprintf("/F%d %d 0 R", i+1, firstFont+i))
+	}
+
+	for i := range d.images {
+		xobjects = append(xobjects, fmt.Sprintf("/Im%d %d 0 R", i+1, firstImage+i))
+	}
+
+	resources = "<< /Font << " + strings.Join(fonts, " ") + " >>"
+	if len(xobjects) > 0 {
+		resources += " /XObject << " + strings.Join(xobjects, " ") + " >>"
+	}
+
+	resources += " >>"
+
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d /MediaBox %s /Resources %s >>",
+		strings.Join(kids, " "), len(d.pages), mediaBox, resources))
+
+	for _, name := range pdfFonts {
+		object("<< /Type /Font /Subtype /Type1 /BaseFont /" + name + " /Encoding /WinAnsiEncoding >>")
+	}
+
+	for _, img := range d.images {
+		dict := fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8",
+			img.width, img.height)
+
+		if err := stream(dict, img.rgb); err != nil {
+			return err
+		}
+	}
+
+	for i, p := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent %d 0 R /Contents %d 0 R >>", pagesObj, firstPage+2*i+1))
+
+		if err := stream("", p.content.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	if len(d.info) > 0 {
+		entries := make([]string, 0, len(d.info))
+
+		for _, e := range d.info {
+			entries = append(entries, "/"+e[0]+" "+pdfText(e[1]))
+		}
+
+		object("<< " + strings.Join(entries, " ") + " >>")
+	}
+
+	for i, a := range attachments {
+		dict := fmt.Sprintf("/Type /EmbeddedFile /Params << /Size %d >>", len(a.Data))
+
+		if err := stream(dict, a.Data); err != nil {
+			return err
+		}
+
+		spec := fmt.Sprintf("<< /Type /Filespec /F (%s) /UF %s /EF << /F %d 0 R /UF %d 0 R >>",
+			pdfEscape(a.Name), pdfText(a.Name), firstAttachment+2*i, firstAttachment+2*i)
+		if a.Description != "" {
+			spec += " /Desc " + pdfText(a.Description)
+		}
+
+		object(spec + " >>")
+	}
+
+	xref := buf.Len()
+
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+
+	trailer := fmt.Sprintf("/Size %d /Root %d 0 R", len(offsets)+1, catalogObj)
+	if len(d.info) > 0 {
+		trailer += fmt.Sprintf(" /Info %d 0 R", infoObj)
+	}
+
+	fmt.Fprintf(&buf, "trailer\n<< %s >>\nstartxref\n%d\n%%%%EOF\n", trailer, xref)
+
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return errorsext.WithStack(err)
+	}
+
+	return nil
+}
+
+func pdfNumber(f float64) string {
+	return strconv.FormatFloat(math.Round(f*100)/100, 'f', -1, 64)
+}
+
+func pdfEscape(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `(`, `\(`, `)`, `\)`, "\r", `\r`, "\n", `\n`)
+
+	return r.Replace(s)
+}
+
+// pdfText encodes s as a text string in UTF-16BE.
+func pdfText(s string) string {
+	return "<" + hex.EncodeToString(utf16BE(s)) + ">"
+}
@@ -0,0 +1,357 @@
+package pdfgen
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gosom/toolkit/pkg/cryptoext"
+	"github.com/gosom/toolkit/pkg/errorsext"
+)
+
+var (
+	ErrQueueFull          = errors.New("render queue is full")
+	ErrInvalidConcurrency = errors.New("max concurrency must be positive")
+)
+
+// Cache stores rendered PDFs by key.
+type Cache interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, pdf []byte)
+}
+
+type PoolOption func(*Pool) error
+
+// WithMaxConcurrency sets how many documents are rendered at the same time.
+// It defaults to 4.
+func WithMaxConcurrency(n int) PoolOption {
+	return func(p *Pool) error {
+		if n <= 0 {
+			return ErrInvalidConcurrency
+		}
+
+		p.sem = make(chan struct{}, n)
+
+		return nil
+	}
+}
+
+// WithMaxQueue sets how many renders may wait for a slot. Renders beyond
+// that fail immediately with ErrQueueFull. By default the queue is unbounded.
+func WithMaxQueue(n int) PoolOption {
+	return func(p *Pool) error {
+		p.maxQueue = int64(n)
+
+		return nil
+	}
+}
+
+// WithJobTimeout limits the time a single render may take once it has
+// a slot. The time spent in the queue is bounded by the context only.
+func WithJobTimeout(timeout time.Duration) PoolOption {
+	return func(p *Pool) error {
+		p.jobTimeout = timeout
+
+		return nil
+	}
+}
+
+// WithCache caches rendered PDFs by the hash of the document and its
+// options, including the contents of the assets. Renders with a BaseURL are
+// not cached, since the files under it may change, and neither are renders
+// with diagnostics, so a cache hit returns an empty Result.
+func WithCache(c Cache) PoolOption {
+	return func(p *Pool) error {
+		p.cache = c
+
+		return nil
+	}
+}
+
+// PoolStats is a snapshot of the metrics of a Pool.
+type PoolStats struct {
+	// Queued is the number of renders waiting for a slot.
+	Queued int64
+	// Running is the number of renders in progress.
+	Running   int64
+	Completed uint64
+	Failed    uint64
+	// Rejected is the number of renders refused because the queue was full.
+	Rejected uint64
+	// Canceled is the number of renders whose context ended while queued.
+	Canceled  uint64
+	CacheHits uint64
+	// QueueTime and RenderTime are the totals over all renders.
+	QueueTime  time.Duration
+	RenderTime time.Duration
+}
+
+// Pool is a Renderer that limits the number of concurrent renders of
+// another Renderer, queueing the rest.
+type Pool struct {
+	r          Renderer
+	sem        chan struct{}
+	maxQueue   int64
+	jobTimeout time.Duration
+	cache      Cache
+
+	queued     atomic.Int64
+	running    atomic.Int64
+	completed  atomic.Uint64
+	failed     atomic.Uint64
+	rejected   atomic.Uint64
+	canceled   atomic.Uint64
+	cacheHits  atomic.Uint64
+	queueTime  atomic.Int64
+	renderTime atomic.Int64
+}
+
+// NewPool creates a Pool rendering with r.
+func NewPool(r Renderer, opts ...PoolOption) (*Pool, error) {
+	const defaultConcurrency = 4
+
+	ans := Pool{
+		r:   r,
+		sem: make(chan struct{}, defaultConcurrency),
+	}
+
+	for _, opt := range opts {
+		if err := opt(&ans); err != nil {
+			return nil, errorsext.WithStack(err)
+		}
+	}
+
+	return &ans, nil
+}
+
+// Render renders html with the Renderer of the pool. With a cache the
+// document is read into memory to compute its key.
+func (p *Pool) Render(ctx context.Context, w io.Writer, html io.Reader, opts RenderOptions) (*Result, error) {
+	var key string
+
+	cache := p.cache
+	if opts.BaseURL != "" {
+		cache = nil
+	}
+
+	if cache != nil {
+		doc, err := io.ReadAll(html)
+		if err != nil {
+			return nil, errorsext.WithStack(err)
+		}
+
+		html = bytes.NewReader(doc)
+
+		key, err = cacheKey(doc, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		if pdf, ok := cache.Get(key); ok {
+			p.cacheHits.Add(1)
+
+			if _, err := w.Write(pdf); err != nil {
+				return nil, errorsext.WithStack(err)
+			}
+
+			return &Result{}, nil
+		}
+	}
+
+	if err := p.acquire(ctx); err != nil {
+		return nil, err
+	}
+
+	defer p.release()
+
+	if p.jobTimeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, p.jobTimeout)
+		defer cancel()
+	}
+
+	out := w
+
+	var buf bytes.Buffer
+
+	if cache != nil {
+		out = &buf
+	}
+
+	start := time.Now()
+
+	result, err := p.r.Render(ctx, out, html, opts)
+
+	p.renderTime.Add(int64(time.Since(start)))
+
+	if err != nil {
+		p.failed.Add(1)
+
+		return result, err
+	}
+
+	p.completed.Add(1)
+
+	if cache != nil {
+		if result == nil || len(result.Diagnostics) == 0 {
+			cache.Set(key, bytes.Clone(buf.Bytes()))
+		}
+
+		if _, err := w.Write(buf.Bytes()); err != nil {
+			return result, errorsext.WithStack(err)
+		}
+	}
+
+	return result, nil
+}
+
+// Stats returns the current metrics.
+func (p *Pool) Stats() PoolStats {
+	return PoolStats{
+		Queued:     p.queued.Load(),
+		Running:    p.running.Load(),
+		Completed:  p.completed.Load(),
+		Failed:     p.failed.Load(),
+		Rejected:   p.rejected.Load(),
+		Canceled:   p.canceled.Load(),
+		CacheHits:  p.cacheHits.Load(),
+		QueueTime:  time.Duration(p.queueTime.Load()),
+		RenderTime: time.Duration(p.renderTime.Load()),
+	}
+}
+
+func (p *Pool) acquire(ctx context.Context) error {
+	if queued := p.queued.Add(1); p.maxQueue > 0 && queued > p.maxQueue {
+		p.queued.Add(-1)
+		p.rejected.Add(1)
+
+		return errorsext.WithStack(ErrQueueFull)
+	}
+
+	start := time.Now()
+
+	defer func() {
+		p.queued.Add(-1)
+		p.queueTime.Add(int64(time.Since(start)))
+	}()
+
+	select {
+	case p.sem <- struct{}{}:
+		p.running.Add(1)
+
+		return nil
+	case <-ctx.Done():
+		p.canceled.Add(1)
+
+		return errorsext.WithStack(ctx.Err())
+	}
+}
+
+func (p *Pool) release() {
+	p.running.Add(-1)
+	<-p.sem
+}
+
+// cacheKey hashes the document, the options and the assets.
+// Renders with a BaseURL are not cached, so the files under it are not hashed.
+func cacheKey(html []byte, opts RenderOptions) (string, error) {
+	var b bytes.Buffer
+
+	b.Write(html)
+	fmt.Fprintf(&b, "\x00%q\x00%q\x00%#v\x00%#v\x00%q\x00%t",
+		opts.BaseURL, opts.Stylesheets, opts.Page, opts.Metadata, opts.Variant, opts.Validate)
+
+	for _, a := range opts.Attachments {
+		fmt.Fprintf(&b, "\x00%q\x00%q\x00%s", a.Name, a.Description, cryptoext.Hash(a.Data))
+	}
+
+	if opts.Assets != nil {
+		err := fs.WalkDir(opts.Assets, ".", func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+
+			data, err := fs.ReadFile(opts.Assets, path)
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(&b, "\x00%q\x00%s", path, cryptoext.Hash(data))
+
+			return nil
+		})
+		if err != nil {
+			return "", errorsext.WithStack(err)
+		}
+	}
+
+	return cryptoext.Hash(b.Bytes()), nil
+}
+
+// MemoryCache is an in-memory Cache that evicts the least recently used
+// PDFs when full.
+type MemoryCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[string]*list.Element
+	lru        *list.List
+}
+
+type memoryCacheEntry struct {
+	key string
+	pdf []byte
+}
+
+// NewMemoryCache creates a MemoryCache holding up to maxEntries PDFs.
+func NewMemoryCache(maxEntries int) *MemoryCache {
+	ans := MemoryCache{
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+	}
+
+	return &ans
+}
+
+func (c *MemoryCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	c.lru.MoveToFront(e)
+
+	return e.Value.(*memoryCacheEntry).pdf, true
+}
+
+func (c *MemoryCache) Set(key string, pdf []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[key]; ok {
+		e.Value.(*memoryCacheEntry).pdf = pdf
+		c.lru.MoveToFront(e)
+
+		return
+	}
+
+	c.entries[key] = c.lru.PushFront(&memoryCacheEntry{key: key, pdf: pdf})
+
+	for c.maxEntries > 0 && c.lru.Len() > c.maxEntries {
+		oldest := c.lru.Back()
+
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*memoryCacheEntry).key)
+	}
+}
@@ -0,0 +1,191 @@
+package pdfgen_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/toolkit/pkg/pdfgen"
+)
+
+func Test_Pool_Concurrency(t *testing.T) {
+	t.Parallel()
+
+	var running, maxRunning atomic.Int64
+
+	r := pdfgen.RendererFunc(func(_ context.Context, w io.Writer, html io.Reader, _ pdfgen.RenderOptions) (*pdfgen.Result, error) {
+		n := running.Add(1)
+		defer running.Add(-1)
+
+		for {
+			current := maxRunning.Load()
+			if n <= current || maxRunning.CompareAndSwap(current, n) {
+				break
+			}
+		}
+
+		time.Sleep(10 * time.Millisecond)
+
+		_, err := io.Copy(w, html)
+
+		return &pdfgen.Result{}, err
+	})
+
+	pool, err := pdfgen.NewPool(r, pdfgen.WithMaxConcurrency(2))
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			_, err := pool.Render(context.Background(), io.Discard, strings.NewReader("doc"), pdfgen.RenderOptions{})
+			require.NoError(t, err)
+		}()
+	}
+
+	wg.Wait()
+
+	require.Equal(t, int64(2), maxRunning.Load())
+
+	stats := pool.Stats()
+	require.Equal(t, uint64(10), stats.Completed)
+	require.Zero(t, stats.Queued)
+	require.Zero(t, stats.Running)
+	require.Positive(t, stats.RenderTime)
+}
+
+func Test_Pool_Queue(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	r := pdfgen.RendererFunc(func(ctx context.Context, _ io.Writer, _ io.Reader, _ pdfgen.RenderOptions) (*pdfgen.Result, error) {
+		started <- struct{}{}
+
+		select {
+		case <-release:
+			return &pdfgen.Result{}, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	})
+
+	pool, err := pdfgen.NewPool(r, pdfgen.WithMaxConcurrency(1), pdfgen.WithMaxQueue(1))
+	require.NoError(t, err)
+
+	done := make(chan error)
+
+	go func() {
+		_, err := pool.Render(context.Background(), io.Discard, strings.NewReader(""), pdfgen.RenderOptions{})
+		done <- err
+	}()
+
+	<-started
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		_, err := pool.Render(ctx, io.Discard, strings.NewReader(""), pdfgen.RenderOptions{})
+		done <- err
+	}()
+
+	require.Eventually(t, func() bool { return pool.Stats().Queued == 1 }, time.Second, time.Millisecond)
+
+	_, err = pool.Render(context.Background(), io.Discard, strings.NewReader(""), pdfgen.RenderOptions{})
+	require.ErrorIs(t, err, pdfgen.ErrQueueFull)
+
+	cancel()
+	require.ErrorIs(t, <-done, context.Canceled)
+
+	close(release)
+	require.NoError(t, <-done)
+
+	stats := pool.Stats()
+	require.Equal(t, uint64(1), stats.Rejected)
+	require.Equal(t, uint64(1), stats.Canceled)
+}
+
+func Test_Pool_JobTimeout(t *testing.T) {
+	t.Parallel()
+
+	r := pdfgen.RendererFunc(func(ctx context.Context, _ io.Writer, _ io.Reader, _ pdfgen.RenderOptions) (*pdfgen.Result, error) {
+		<-ctx.Done()
+
+		return nil, ctx.Err()
+	})
+
+	pool, err := pdfgen.NewPool(r, pdfgen.WithJobTimeout(10*time.Millisecond))
+	require.NoError(t, err)
+
+	_, err = pool.Render(context.Background(), io.Discard, strings.NewReader(""), pdfgen.RenderOptions{})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Equal(t, uint64(1), pool.Stats().Failed)
+}
+
+func Test_Pool_Cache(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int64
+
+	r := pdfgen.RendererFunc(func(_ context.Context, w io.Writer, html io.Reader, _ pdfgen.RenderOptions) (*pdfgen.Result, error) {
+		calls.Add(1)
+
+		var doc bytes.Buffer
+
+		_, err := io.Copy(io.MultiWriter(w, &doc), html)
+
+		result := pdfgen.Result{}
+		if doc.String() == "warn" {
+			result.Diagnostics = []pdfgen.Diagnostic{{Level: pdfgen.LevelWarning, Message: "missing font"}}
+		}
+
+		return &result, err
+	})
+
+	pool, err := pdfgen.NewPool(r, pdfgen.WithCache(pdfgen.NewMemoryCache(1)))
+	require.NoError(t, err)
+
+	render := func(html string, opts pdfgen.RenderOptions) string {
+		buff := bytes.NewBuffer(nil)
+
+		_, err := pool.Render(context.Background(), buff, strings.NewReader(html), opts)
+		require.NoError(t, err)
+
+		return buff.String()
+	}
+
+	require.Equal(t, "a", render("a", pdfgen.RenderOptions{}))
+	require.Equal(t, "a", render("a", pdfgen.RenderOptions{}))
+	require.Equal(t, int64(1), calls.Load())
+
+	render("a", pdfgen.RenderOptions{Page: pdfgen.PageSettings{Size: "A5"}})
+	require.Equal(t, int64(2), calls.Load())
+
+	// the cache holds a single entry, so the first one was evicted
+	render("a", pdfgen.RenderOptions{})
+	require.Equal(t, int64(3), calls.Load())
+	require.Equal(t, uint64(1), pool.Stats().CacheHits)
+
+	// renders with diagnostics are not cached, so the warning is kept
+	render("warn", pdfgen.RenderOptions{})
+	render("warn", pdfgen.RenderOptions{})
+	require.Equal(t, int64(5), calls.Load())
+
+	// files under a base URL may change
+	render("a", pdfgen.RenderOptions{BaseURL: "/srv/templates"})
+	render("a", pdfgen.RenderOptions{BaseURL: "/srv/templates"})
+	require.Equal(t, int64(7), calls.Load())
+	require.Equal(t, uint64(1), pool.Stats().CacheHits)
+}
@@ -0,0 +1,546 @@
+package pdfgen
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gosom/toolkit/pkg/errorsext"
+	"github.com/gosom/toolkit/pkg/logger"
+)
+
+var ErrBinaryNotFound = errors.New("renderer binary not found")
+
+// staleTempDirAge is the age after which render directories are
+// considered left behind by a killed process.
+const staleTempDirAge = time.Hour
+
+// Renderer converts an HTML document read from html to PDF.
+// On failure the error is usually a *RenderError.
+type Renderer interface {
+	Render(ctx context.Context, w io.Writer, html io.Reader, opts RenderOptions) (*Result, error)
+}
+
+// RendererFunc adapts a function to a Renderer, which is mostly useful
+// for fakes in tests.
+type RendererFunc func(ctx context.Context, w io.Writer, html io.Reader, opts RenderOptions) (*Result, error)
+
+func (f RendererFunc) Render(ctx context.Context, w io.Writer, html io.Reader, opts RenderOptions) (*Result, error) {
+	return f(ctx, w, html, opts)
+}
+
+// NewFallbackRenderer returns a Renderer that uses the first of renderers
+// whose binary is installed and that supports the requested variant and
+// media type, e.g. WeasyPrint with a GoRenderer fallback. Renderers report
+// these before they read the document.
+func NewFallbackRenderer(renderers ...Renderer) Renderer {
+	return RendererFunc(func(ctx context.Context, w io.Writer, html io.Reader, opts RenderOptions) (*Result, error) {
+		err := errorsext.WithStack(ErrBinaryNotFound)
+
+		for _, r := range renderers {
+			var result *Result
+
+			result, err = r.Render(ctx, w, html, opts)
+			if errors.Is(err, ErrBinaryNotFound) || errors.Is(err, ErrUnsupportedVariant) || errors.Is(err, ErrUnsupportedMediaType) {
+				continue
+			}
+
+			return result, err
+		}
+
+		return nil, err
+	})
+}
+
+type Option func(*CommandRenderer) error
+
+// WithBinary sets the path of the renderer binary.
+// By default it is looked up in PATH.
+func WithBinary(path string) Option {
+	return func(r *CommandRenderer) error {
+		r.binaries = []string{path}
+
+		return nil
+	}
+}
+
+// WithArgs appends extra command line arguments, which are passed before
+// the input and output arguments.
+func WithArgs(args ...string) Option {
+	return func(r *CommandRenderer) error {
+		r.args = append(r.args, args...)
+
+		return nil
+	}
+}
+
+// WithTempDir sets the directory in which a directory is created for
+// every render that needs files, e.g. for assets or for renderers that
+// cannot read the document from stdin. It defaults to pdfgen in
+// os.TempDir(). See CleanTempDir for directories of killed processes.
+func WithTempDir(dir string) Option {
+	return func(r *CommandRenderer) error {
+		r.tempDir = dir
+
+		return nil
+	}
+}
+
+// WithLogger sets the logger diagnostics are logged to.
+func WithLogger(log logger.Logger) Option {
+	return func(r *CommandRenderer) error {
+		r.log = log
+
+		return nil
+	}
+}
+
+// WithStrict makes rendering fail with ErrWarnings when the renderer
+// reports any diagnostic, even if it produced a PDF.
+func WithStrict() Option {
+	return func(r *CommandRenderer) error {
+		r.strict = true
+
+		return nil
+	}
+}
+
+// CommandRenderer renders PDFs by running an external program.
+type CommandRenderer struct {
+	binaries []string
+	args     []string
+	// command returns the arguments to render in to out. They are "-" when
+	// stdio is set.
+	command func(args []string, in, out string) []string
+	// stdio is set when the renderer reads the document from stdin and
+	// writes the PDF to stdout.
+	stdio   bool
+	tempDir string
+	// pageArgs returns the arguments for the page settings that are not
+	// set with CSS.
+	pageArgs func(page PageSettings) []string
+	// pageCSS adds the page size and margins to the document as CSS.
+	pageCSS bool
+	// mediaTypes are the supported media types. Any is supported when it
+	// is nil.
+	mediaTypes []string
+	// variants are the arguments for the supported variants.
+	variants map[Variant][]string
+	// attachmentArgs returns the arguments to attach the file at path.
+	// Without it attachments are added with pdfcpu.
+	attachmentArgs func(path string) []string
+	// metadata is set when the renderer reads the metadata from the title
+	// and meta elements. Otherwise it is added with pdfcpu.
+	metadata bool
+	// diagnostics parses the standard error of the renderer.
+	diagnostics func(stderr string) []Diagnostic
+	log         logger.Logger
+	strict      bool
+}
+
+// NewWeasyPrint creates a renderer using WeasyPrint (https://weasyprint.org).
+func NewWeasyPrint(opts ...Option) (*CommandRenderer, error) {
+	ans := CommandRenderer{
+		binaries: []string{"weasyprint"},
+		log:      logger.Default(),
+		stdio:    true,
+		pageCSS:  true,
+		metadata: true,
+		variants: map[Variant][]string{
+			PDFA3b: {"--pdf-variant", "pdf/a-3b"},
+			PDFUA1: {"--pdf-variant", "pdf/ua-1"},
+		},
+		attachmentArgs: func(path string) []string {
+			return []string{"--attachment", path}
+		},
+		command: func(args []string, in, out string) []string {
+			return append(args, in, out)
+		},
+		pageArgs: func(page PageSettings) []string {
+			if page.MediaType == "" {
+				return nil
+			}
+
+			return []string{"--media-type", page.MediaType}
+		},
+	}
+
+	return ans.apply(opts)
+}
+
+// NewWkhtmltopdf creates a renderer using wkhtmltopdf (https://wkhtmltopdf.org).
+func NewWkhtmltopdf(opts ...Option) (*CommandRenderer, error) {
+	ans := CommandRenderer{
+		binaries: []string{"wkhtmltopdf"},
+		log:      logger.Default(),
+		stdio:    true,
+		command: func(args []string, in, out string) []string {
+			return append(append([]string{"--quiet", "--enable-local-file-access"}, args...), in, out)
+		},
+		pageArgs: wkhtmltopdfPageArgs,
+	}
+
+	return ans.apply(opts)
+}
+
+// NewChromium creates a renderer using a headless Chromium or Chrome.
+// Without WithBinary the first of chromium, chromium-browser,
+// google-chrome and chrome found in PATH is used.
+func NewChromium(opts ...Option) (*CommandRenderer, error) {
+	ans := CommandRenderer{
+		binaries:    []string{"chromium", "chromium-browser", "google-chrome", "chrome"},
+		log:         logger.Default(),
+		pageCSS:     true,
+		mediaTypes:  []string{"print"},
+		diagnostics: parseChromiumDiagnostics,
+		command: func(args []string, in, out string) []string {
+			ans := []string{
+				"--headless",
+				"--disable-gpu",
+				"--no-pdf-header-footer",
+				"--print-to-pdf=" + out,
+			}
+
+			return append(append(ans, args...), "file://"+in)
+		},
+	}
+
+	return ans.apply(opts)
+}
+
+// Render runs the renderer. With stdio the document is streamed to the
+// renderer and, unless it has to be processed first, the PDF is streamed
+// to w, so on failure part of it may have been written already.
+func (r *CommandRenderer) Render(ctx context.Context, w io.Writer, html io.Reader, opts RenderOptions) (*Result, error) {
+	variantArgs, ok := r.variants[opts.Variant]
+	if opts.Variant != "" && !ok {
+		return nil, errorsext.WithStack(fmt.Errorf("%w: %s", ErrUnsupportedVariant, opts.Variant))
+	}
+
+	if mt := opts.Page.MediaType; mt != "" && r.mediaTypes != nil && !slices.Contains(r.mediaTypes, mt) {
+		return nil, errorsext.WithStack(fmt.Errorf("%w: %s", ErrUnsupportedMediaType, mt))
+	}
+
+	if err := validateAttachments(opts.Attachments); err != nil {
+		return nil, err
+	}
+
+	binary, err := r.binary()
+	if err != nil {
+		return nil, err
+	}
+
+	var dir string
+
+	if !r.stdio || (opts.Assets != nil && opts.BaseURL == "") || (r.attachmentArgs != nil && len(opts.Attachments) > 0) {
+		if err = os.MkdirAll(r.tempDir, 0o700); err != nil {
+			return nil, errorsext.WithStack(err)
+		}
+
+		dir, err = os.MkdirTemp(r.tempDir, "render")
+		if err != nil {
+			return nil, errorsext.WithStack(err)
+		}
+
+		defer os.RemoveAll(dir)
+	}
+
+	if opts.Assets != nil && dir != "" {
+		if err = writeAssets(dir, opts.Assets); err != nil {
+			return nil, err
+		}
+
+		// a document read from stdin has no location relative URLs
+		// resolve against
+		if r.stdio && opts.BaseURL == "" {
+			opts.BaseURL = dir
+		}
+	}
+
+	html, err = prepareHTML(html, opts, r.pageCSS)
+	if err != nil {
+		return nil, err
+	}
+
+	args := append([]string(nil), r.args...)
+
+	if r.pageArgs != nil {
+		args = append(args, r.pageArgs(opts.Page)...)
+	}
+
+	args = append(args, variantArgs...)
+
+	if r.attachmentArgs != nil && len(opts.Attachments) > 0 {
+		attachmentArgs, err := r.writeAttachments(dir, opts.Attachments)
+		if err != nil {
+			return nil, err
+		}
+
+		args = append(args, attachmentArgs...)
+	}
+
+	in, out := "-", "-"
+
+	if !r.stdio {
+		// the document is written next to the assets, so that relative
+		// URLs resolve to them.
+		in = filepath.Join(dir, "document.html")
+		out = filepath.Join(dir, "document.pdf")
+
+		if err = writeFile(in, html); err != nil {
+			return nil, err
+		}
+	}
+
+	withMetadata := (!r.metadata && !opts.Metadata.isZero()) || (r.attachmentArgs == nil && len(opts.Attachments) > 0)
+	buffered := r.strict || opts.Validate || withMetadata
+
+	var stderr, stdout bytes.Buffer
+
+	//nolint:gosec // the binary is configured by the application
+	cmd := exec.CommandContext(ctx, binary, r.command(args, in, out)...)
+	cmd.Stderr = &stderr
+
+	if r.stdio {
+		cmd.Stdin = html
+		cmd.Stdout = w
+
+		if buffered {
+			cmd.Stdout = &stdout
+		}
+	}
+
+	err = cmd.Run()
+
+	result := Result{
+		Diagnostics: r.diagnostics(stderr.String()),
+		Stderr:      stderr.String(),
+	}
+
+	r.logDiagnostics(ctx, binary, result.Diagnostics)
+
+	if err != nil {
+		return &result, errorsext.WithStack(r.renderError(&result, cmd, err))
+	}
+
+	if r.strict && len(result.Diagnostics) > 0 {
+		return &result, errorsext.WithStack(r.renderError(&result, cmd, ErrWarnings))
+	}
+
+	if r.stdio && !buffered {
+		return &result, nil
+	}
+
+	var pdf io.ReadSeeker = bytes.NewReader(stdout.Bytes())
+
+	if !r.stdio {
+		pdfFile, err := os.Open(out)
+		if err != nil {
+			return &result, errorsext.WithStack(err)
+		}
+
+		defer pdfFile.Close()
+
+		pdf = pdfFile
+	}
+
+	if withMetadata {
+		var buf bytes.Buffer
+
+		if err = addMetadata(&buf, pdf, opts.Metadata, opts.Attachments); err != nil {
+			return &result, err
+		}
+
+		pdf = bytes.NewReader(buf.Bytes())
+	}
+
+	if opts.Validate {
+		if err = ValidatePDF(pdf); err != nil {
+			return &result, err
+		}
+
+		if _, err = pdf.Seek(0, io.SeekStart); err != nil {
+			return &result, errorsext.WithStack(err)
+		}
+	}
+
+	if _, err = io.Copy(w, pdf); err != nil {
+		return &result, errorsext.WithStack(err)
+	}
+
+	return &result, nil
+}
+
+// writeAttachments writes the attachments to a directory of their own
+// in dir and returns the arguments to attach them.
+func (r *CommandRenderer) writeAttachments(dir string, attachments []Attachment) ([]string, error) {
+	dir, err := os.MkdirTemp(dir, "attachments")
+	if err != nil {
+		return nil, errorsext.WithStack(err)
+	}
+
+	var ans []string
+
+	for _, a := range attachments {
+		path := filepath.Join(dir, a.Name)
+
+		if err := os.WriteFile(path, a.Data, 0o600); err != nil {
+			return nil, errorsext.WithStack(err)
+		}
+
+		ans = append(ans, r.attachmentArgs(path)...)
+	}
+
+	return ans, nil
+}
+
+func (r *CommandRenderer) renderError(result *Result, cmd *exec.Cmd, err error) *RenderError {
+	ans := RenderError{
+		ExitCode:    -1,
+		Stderr:      result.Stderr,
+		Diagnostics: result.Diagnostics,
+		Err:         err,
+	}
+
+	if cmd.ProcessState != nil {
+		ans.ExitCode = cmd.ProcessState.ExitCode()
+	}
+
+	return &ans
+}
+
+func (r *CommandRenderer) logDiagnostics(ctx context.Context, binary string, diagnostics []Diagnostic) {
+	for _, d := range diagnostics {
+		if d.Level == LevelError {
+			r.log.Error(ctx, "pdf renderer error", "renderer", binary, "message", d.Message)
+		} else {
+			r.log.Info(ctx, "pdf renderer warning", "renderer", binary, "message", d.Message)
+		}
+	}
+}
+
+func (r *CommandRenderer) apply(opts []Option) (*CommandRenderer, error) {
+	for _, opt := range opts {
+		if err := opt(r); err != nil {
+			return nil, errorsext.WithStack(err)
+		}
+	}
+
+	if r.tempDir == "" {
+		r.tempDir = defaultTempDir()
+	}
+
+	if r.diagnostics == nil {
+		r.diagnostics = parseDiagnostics
+	}
+
+	return r, nil
+}
+
+// CleanTempDir removes the render directories older than an hour from
+// dir, which are left behind by killed processes. Call it on startup.
+// An empty dir is the default of WithTempDir.
+func CleanTempDir(dir string) error {
+	if dir == "" {
+		dir = defaultTempDir()
+	}
+
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return errorsext.WithStack(err)
+	}
+
+	var errs []error
+
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil || !e.IsDir() || !strings.HasPrefix(e.Name(), "render") || time.Since(info.ModTime()) < staleTempDirAge {
+			continue
+		}
+
+		if err := os.RemoveAll(filepath.Join(dir, e.Name())); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errorsext.WithStack(errors.Join(errs...))
+}
+
+func defaultTempDir() string {
+	return filepath.Join(os.TempDir(), "pdfgen")
+}
+
+func writeFile(path string, r io.Reader) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return errorsext.WithStack(err)
+	}
+
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+
+		return errorsext.WithStack(err)
+	}
+
+	return errorsext.WithStack(f.Close())
+}
+
+func (r *CommandRenderer) binary() (string, error) {
+	for _, name := range r.binaries {
+		if path, err := exec.LookPath(name); err == nil {
+			return path, nil
+		}
+	}
+
+	return "", errorsext.WithStack(ErrBinaryNotFound)
+}
+
+func wkhtmltopdfPageArgs(page PageSettings) []string {
+	var ans []string
+
+	if width, height, ok := strings.Cut(strings.TrimSpace(page.Size), " "); ok {
+		ans = append(ans, "--page-width", width, "--page-height", strings.TrimSpace(height))
+	} else if page.Size != "" {
+		ans = append(ans, "--page-size", page.Size)
+	}
+
+	switch page.Orientation {
+	case Portrait:
+		ans = append(ans, "--orientation", "Portrait")
+	case Landscape:
+		ans = append(ans, "--orientation", "Landscape")
+	}
+
+	for _, m := range []struct{ flag, value string }{
+		{"--margin-top", page.Margins.Top},
+		{"--margin-right", page.Margins.Right},
+		{"--margin-bottom", page.Margins.Bottom},
+		{"--margin-left", page.Margins.Left},
+	} {
+		if m.value != "" {
+			ans = append(ans, m.flag, m.value)
+		}
+	}
+
+	switch page.MediaType {
+	case "print":
+		ans = append(ans, "--print-media-type")
+	case "screen":
+		ans = append(ans, "--no-print-media-type")
+	}
+
+	return ans
+}
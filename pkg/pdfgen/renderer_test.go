@@ -0,0 +1,364 @@
+package pdfgen_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"testing/iotest"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/toolkit/pkg/pdfgen"
+)
+
+// fakeBinary writes a script that behaves like weasyprint reading from
+// stdin and writing to stdout: it writes its arguments, the files in the
+// directory of the base URL of the document and the document.
+func fakeBinary(t *testing.T) string {
+	t.Helper()
+
+	return scriptBinary(t, `html=$(cat)
+dir=$(printf '%s' "$html" | sed -n 's#.*<base href="file://\([^"]*\)".*#\1#p')
+echo "$@"
+[ -d "$dir" ] && ls "$dir"
+printf '%s\n' "$html"
+`)
+}
+
+func Test_CommandRenderer(t *testing.T) {
+	t.Parallel()
+
+	r, err := pdfgen.NewWeasyPrint(pdfgen.WithBinary(fakeBinary(t)), pdfgen.WithArgs("--encoding", "utf-8"))
+	require.NoError(t, err)
+
+	buff := bytes.NewBuffer(nil)
+
+	_, err = r.Render(context.Background(), buff, strings.NewReader("<h1>test</h1>"), pdfgen.RenderOptions{})
+	require.NoError(t, err)
+	require.Contains(t, buff.String(), "--encoding utf-8")
+	require.Contains(t, buff.String(), "<h1>test</h1>")
+}
+
+func scriptBinary(t *testing.T, script string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "fake-renderer")
+
+	require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0o700))
+
+	return path
+}
+
+func Test_CommandRenderer_Diagnostics(t *testing.T) {
+	t.Parallel()
+
+	binary := scriptBinary(t, `echo "WARNING: Failed to load image at 'logo.png'" >&2
+echo "some progress output" >&2
+echo "%PDF"
+`)
+
+	r, err := pdfgen.NewWeasyPrint(pdfgen.WithBinary(binary))
+	require.NoError(t, err)
+
+	buff := bytes.NewBuffer(nil)
+
+	result, err := r.Render(context.Background(), buff, strings.NewReader("<img src='logo.png'>"), pdfgen.RenderOptions{})
+	require.NoError(t, err)
+	require.Equal(t, []pdfgen.Diagnostic{
+		{Level: pdfgen.LevelWarning, Message: "Failed to load image at 'logo.png'"},
+	}, result.Warnings())
+	require.Empty(t, result.Errors())
+	require.Equal(t, "%PDF\n", buff.String())
+
+	strict, err := pdfgen.NewWeasyPrint(pdfgen.WithBinary(binary), pdfgen.WithStrict())
+	require.NoError(t, err)
+
+	buff.Reset()
+
+	_, err = strict.Render(context.Background(), buff, strings.NewReader("<img src='logo.png'>"), pdfgen.RenderOptions{})
+	require.ErrorIs(t, err, pdfgen.ErrWarnings)
+	require.Empty(t, buff.Bytes())
+}
+
+func Test_Chromium_Diagnostics(t *testing.T) {
+	t.Parallel()
+
+	binary := scriptBinary(t, `for a in "$@"; do
+	case "$a" in --print-to-pdf=*) out="${a#--print-to-pdf=}" ;; esac
+done
+cat >&2 <<'EOF'
+[0101/120000.000001:ERROR:bus.cc(407)] Failed to connect to the bus: Failed to connect to socket /run/dbus/system_bus_socket: No such file or directory
+[0101/120000.000002:ERROR:gpu_init.cc(523)] Passthrough is not supported, GL is disabled, ANGLE is
+[0101/120000.000003:WARNING:sandbox_linux.cc(393)] InitializeSandbox() called with multiple threads in process gpu-process.
+[0101/120000.000004:ERROR:viz_main_impl.cc(186)] Exiting GPU process due to errors during initialization
+[0101/120000.000005:INFO:CONSOLE(0)] "Failed to load resource: net::ERR_FILE_NOT_FOUND", source: file:///tmp/logo.png (0)
+[0101/120000.000006:ERROR:CONSOLE(3)] "Uncaught ReferenceError: total is not defined", source: file:///tmp/document.html (3)
+1234 bytes written to file /tmp/document.pdf
+EOF
+echo "%PDF" > "$out"
+`)
+
+	r, err := pdfgen.NewChromium(pdfgen.WithBinary(binary))
+	require.NoError(t, err)
+
+	buff := bytes.NewBuffer(nil)
+
+	result, err := r.Render(context.Background(), buff, strings.NewReader("<img src='logo.png'>"), pdfgen.RenderOptions{})
+	require.NoError(t, err)
+	require.Equal(t, []pdfgen.Diagnostic{
+		{Level: pdfgen.LevelWarning, Message: `"Failed to load resource: net::ERR_FILE_NOT_FOUND", source: file:///tmp/logo.png (0)`},
+		{Level: pdfgen.LevelError, Message: `"Uncaught ReferenceError: total is not defined", source: file:///tmp/document.html (3)`},
+	}, result.Diagnostics)
+	require.Equal(t, "%PDF\n", buff.String())
+
+	// the startup noise alone does not fail strict renders
+	quiet := scriptBinary(t, `for a in "$@"; do
+	case "$a" in --print-to-pdf=*) out="${a#--print-to-pdf=}" ;; esac
+done
+echo "[0101/120000.000001:ERROR:bus.cc(407)] Failed to connect to the bus" >&2
+echo "%PDF" > "$out"
+`)
+
+	strict, err := pdfgen.NewChromium(pdfgen.WithBinary(quiet), pdfgen.WithStrict())
+	require.NoError(t, err)
+
+	_, err = strict.Render(context.Background(), io.Discard, strings.NewReader("<h1>test</h1>"), pdfgen.RenderOptions{})
+	require.NoError(t, err)
+}
+
+func Test_Chromium_MediaType(t *testing.T) {
+	t.Parallel()
+
+	binary := scriptBinary(t, `for a in "$@"; do
+	case "$a" in --print-to-pdf=*) out="${a#--print-to-pdf=}" ;; esac
+done
+echo "%PDF" > "$out"
+`)
+
+	r, err := pdfgen.NewChromium(pdfgen.WithBinary(binary))
+	require.NoError(t, err)
+
+	_, err = r.Render(context.Background(), io.Discard, strings.NewReader("<h1>test</h1>"), pdfgen.RenderOptions{
+		Page: pdfgen.PageSettings{MediaType: "print"},
+	})
+	require.NoError(t, err)
+
+	screen := pdfgen.RenderOptions{Page: pdfgen.PageSettings{MediaType: "screen"}}
+
+	_, err = r.Render(context.Background(), io.Discard, strings.NewReader("<h1>test</h1>"), screen)
+	require.ErrorIs(t, err, pdfgen.ErrUnsupportedMediaType)
+
+	// the fallback skips renderers that cannot apply the media type
+	weasyprint, err := pdfgen.NewWeasyPrint(pdfgen.WithBinary(fakeBinary(t)))
+	require.NoError(t, err)
+
+	buff := bytes.NewBuffer(nil)
+
+	_, err = pdfgen.NewFallbackRenderer(r, weasyprint).Render(context.Background(), buff, strings.NewReader("<h1>test</h1>"), screen)
+	require.NoError(t, err)
+	require.Contains(t, buff.String(), "--media-type screen")
+}
+
+func Test_CommandRenderer_Failure(t *testing.T) {
+	t.Parallel()
+
+	binary := scriptBinary(t, `echo "ERROR: Failed to parse stylesheet" >&2
+exit 3
+`)
+
+	r, err := pdfgen.NewWkhtmltopdf(pdfgen.WithBinary(binary))
+	require.NoError(t, err)
+
+	result, err := r.Render(context.Background(), io.Discard, strings.NewReader("<h1>test</h1>"), pdfgen.RenderOptions{})
+
+	var renderErr *pdfgen.RenderError
+
+	require.ErrorAs(t, err, &renderErr)
+	require.Equal(t, 3, renderErr.ExitCode)
+	require.Contains(t, renderErr.Error(), "Failed to parse stylesheet")
+	require.Len(t, result.Errors(), 1)
+}
+
+func Test_CommandRenderer_BinaryNotFound(t *testing.T) {
+	t.Parallel()
+
+	r, err := pdfgen.NewChromium(pdfgen.WithBinary("/nonexistent/chrome"))
+	require.NoError(t, err)
+
+	_, err = r.Render(context.Background(), io.Discard, strings.NewReader("<h1>test</h1>"), pdfgen.RenderOptions{})
+	require.ErrorIs(t, err, pdfgen.ErrBinaryNotFound)
+}
+
+func Test_RendererFunc(t *testing.T) {
+	t.Parallel()
+
+	var r pdfgen.Renderer = pdfgen.RendererFunc(func(_ context.Context, w io.Writer, html io.Reader, _ pdfgen.RenderOptions) (*pdfgen.Result, error) {
+		_, err := io.Copy(w, html)
+
+		return &pdfgen.Result{}, err
+	})
+
+	buff := bytes.NewBuffer(nil)
+
+	_, err := r.Render(context.Background(), buff, strings.NewReader("%PDF"), pdfgen.RenderOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "%PDF", buff.String())
+}
+
+func Test_CommandRenderer_Options(t *testing.T) {
+	t.Parallel()
+
+	opts := pdfgen.RenderOptions{
+		BaseURL: "/srv/templates",
+		Assets: fstest.MapFS{
+			"css/invoice.css": &fstest.MapFile{Data: []byte("h1 { color: red }")},
+			"logo.png":        &fstest.MapFile{Data: []byte("png")},
+		},
+		Stylesheets: []string{"body { font-size: 10pt }"},
+		Page: pdfgen.PageSettings{
+			Size:        "A4",
+			Orientation: pdfgen.Landscape,
+			Margins:     pdfgen.Margins{Top: "10mm", Bottom: "10mm"},
+			MediaType:   "screen",
+		},
+	}
+
+	html := []byte(`<html><head><title>t</title></head><body><header>h</header></body></html>`)
+
+	weasyprint, err := pdfgen.NewWeasyPrint(pdfgen.WithBinary(fakeBinary(t)))
+	require.NoError(t, err)
+
+	buff := bytes.NewBuffer(nil)
+
+	_, err = weasyprint.Render(context.Background(), buff, bytes.NewReader(html), opts)
+	require.NoError(t, err)
+
+	out := buff.String()
+	require.Contains(t, out, "--media-type screen - -")
+	require.Contains(t, out, `<head><base href="file:///srv/templates/">`+
+		`<style>@page { size: A4 landscape; margin: 10mm 0 10mm 0; }</style>`+
+		`<style>body { font-size: 10pt }</style><title>`)
+
+	wkhtmltopdf, err := pdfgen.NewWkhtmltopdf(pdfgen.WithBinary(fakeBinary(t)))
+	require.NoError(t, err)
+
+	buff.Reset()
+
+	_, err = wkhtmltopdf.Render(context.Background(), buff, bytes.NewReader(html), opts)
+	require.NoError(t, err)
+
+	out = buff.String()
+	require.Contains(t, out, "--page-size A4 --orientation Landscape --margin-top 10mm --margin-bottom 10mm --no-print-media-type")
+	require.NotContains(t, out, "@page")
+
+	// without a base URL the assets are written to a directory the
+	// document is based on
+	opts.BaseURL = ""
+
+	buff.Reset()
+
+	_, err = weasyprint.Render(context.Background(), buff, bytes.NewReader(html), opts)
+	require.NoError(t, err)
+
+	out = buff.String()
+	require.Contains(t, out, "css\n")
+	require.Contains(t, out, "logo.png\n")
+	require.Contains(t, out, `<head><base href="file://`)
+}
+
+func Test_CommandRenderer_Head(t *testing.T) {
+	t.Parallel()
+
+	r, err := pdfgen.NewWeasyPrint(pdfgen.WithBinary(fakeBinary(t)))
+	require.NoError(t, err)
+
+	opts := pdfgen.RenderOptions{Metadata: pdfgen.Metadata{Title: "Invoice", Author: "ACME"}}
+
+	for html, expected := range map[string]string{
+		// the head is added after the doctype to keep standards mode
+		`<!DOCTYPE html><p>text</p>`:                              `<!DOCTYPE html><head><title>Invoice</title><meta name="author" content="ACME"></head><p>text</p>`,
+		`<!DOCTYPE html><html lang="en"><body>text</body></html>`: `<html lang="en"><head><title>Invoice</title><meta name="author" content="ACME"></head><body>`,
+		// the title of the document is kept
+		`<html><head><title>t</title></head></html>`: `<head><meta name="author" content="ACME"><title>t</title></head>`,
+	} {
+		buff := bytes.NewBuffer(nil)
+
+		_, err := r.Render(context.Background(), buff, strings.NewReader(html), opts)
+		require.NoError(t, err)
+		require.Contains(t, buff.String(), expected)
+		require.Equal(t, 1, strings.Count(buff.String(), "<title>"))
+	}
+}
+
+func Test_CommandRenderer_TempDir(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	stale := filepath.Join(dir, "render-stale")
+	fresh := filepath.Join(dir, "render-fresh")
+	other := filepath.Join(dir, "other")
+
+	for _, d := range []string{stale, fresh, other} {
+		require.NoError(t, os.Mkdir(d, 0o700))
+	}
+
+	old := time.Now().Add(-2 * time.Hour)
+	require.NoError(t, os.Chtimes(stale, old, old))
+	require.NoError(t, os.Chtimes(other, old, old))
+
+	// a fake chromium, which cannot read the document from stdin
+	binary := scriptBinary(t, `for arg in "$@"; do
+	case "$arg" in
+	--print-to-pdf=*) out="${arg#--print-to-pdf=}" ;;
+	file://*) in="${arg#file://}" ;;
+	esac
+done
+{ echo "$in"; cat "$in"; } > "$out"
+`)
+
+	r, err := pdfgen.NewChromium(pdfgen.WithBinary(binary), pdfgen.WithTempDir(dir))
+	require.NoError(t, err)
+
+	// creating a renderer does not touch the directory
+	require.DirExists(t, stale)
+
+	require.NoError(t, pdfgen.CleanTempDir(dir))
+
+	require.NoDirExists(t, stale)
+	require.DirExists(t, fresh)
+	require.DirExists(t, other)
+
+	buff := bytes.NewBuffer(nil)
+
+	_, err = r.Render(context.Background(), buff, strings.NewReader("<h1>test</h1>"), pdfgen.RenderOptions{})
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(buff.String(), dir), buff.String())
+	require.Contains(t, buff.String(), "<h1>test</h1>")
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 2, "the render directory is removed")
+}
+
+func Test_CommandRenderer_Stream(t *testing.T) {
+	t.Parallel()
+
+	r, err := pdfgen.NewWeasyPrint(pdfgen.WithBinary(fakeBinary(t)))
+	require.NoError(t, err)
+
+	body := strings.Repeat("<p>line</p>", 10000)
+	html := iotest.OneByteReader(strings.NewReader(`<html><head><meta charset="utf-8"></head><body>` + body + `</body></html>`))
+
+	buff := bytes.NewBuffer(nil)
+
+	_, err = r.Render(context.Background(), buff, html, pdfgen.RenderOptions{Stylesheets: []string{"p { margin: 0 }"}})
+	require.NoError(t, err)
+	require.Contains(t, buff.String(), `<head><style>p { margin: 0 }</style><meta charset="utf-8"></head><body>`+body+`</body>`)
+}
@@ -0,0 +1,51 @@
+package pdfgen
+
+import (
+	"bytes"
+	"context"
+	"html/template"
+	"io"
+
+	"github.com/gosom/toolkit/pkg/qrgen"
+	"github.com/gosom/toolkit/pkg/templates"
+)
+
+// TemplateFuncs returns template functions for documents:
+//
+//	qrcode returns a QR code of its argument as a data URI,
+//	e.g. <img src="{{qrcode .PaymentURL}}">
+func TemplateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"qrcode": func(data string) template.URL {
+			//nolint:gosec // the data URI is generated, not user input
+			return template.URL(qrgen.GetQRCode(data))
+		},
+	}
+}
+
+// RegisterTemplateFuncs adds TemplateFuncs to tr.
+// It must be called before the templates are added.
+func RegisterTemplateFuncs(tr *templates.TemplateRenderer) {
+	for name, fn := range TemplateFuncs() {
+		tr.AddTemplateFunc(name, fn)
+	}
+}
+
+// GenerateFromTemplate executes the template name of tr with data,
+// localized for lang, and renders the result to PDF with r.
+func GenerateFromTemplate(ctx context.Context, w io.Writer, r Renderer, tr *templates.TemplateRenderer, name string, data any, lang string) (*Result, error) {
+	return GenerateFromTemplateWithOptions(ctx, w, r, tr, name, data, lang, RenderOptions{})
+}
+
+// GenerateFromTemplateWithOptions is like GenerateFromTemplate with RenderOptions.
+// The template is executed before rendering, so a failing template does
+// not leave a partial PDF in w.
+func GenerateFromTemplateWithOptions(ctx context.Context, w io.Writer, r Renderer, tr *templates.TemplateRenderer, name string, data any, lang string, opts RenderOptions) (*Result, error) {
+	var html bytes.Buffer
+
+	if err := tr.Execute(&html, name, data, lang); err != nil {
+		return nil, err
+	}
+
+	return r.Render(ctx, w, &html, opts)
+}
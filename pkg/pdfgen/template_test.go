@@ -0,0 +1,79 @@
+package pdfgen_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"testing/fstest"
+
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/language"
+
+	"github.com/gosom/toolkit/pkg/pdfgen"
+	"github.com/gosom/toolkit/pkg/templates"
+)
+
+func Test_TemplateFuncs(t *testing.T) {
+	t.Parallel()
+
+	tr := templates.New()
+	pdfgen.RegisterTemplateFuncs(tr)
+
+	fsys := fstest.MapFS{
+		"invoice.html": &fstest.MapFile{Data: []byte(`<img src="{{qrcode .}}">`)},
+	}
+
+	require.NoError(t, tr.Add(fsys, "*.html"))
+
+	buff := bytes.NewBuffer(nil)
+
+	require.NoError(t, tr.Execute(buff, "invoice.html", "https://example.com/pay", ""))
+	require.Contains(t, buff.String(), `<img src="data:image/png;base64,`)
+}
+
+func Test_GenerateFromTemplate(t *testing.T) {
+	t.Parallel()
+
+	bundle := i18n.NewBundle(language.English)
+	bundle.MustAddMessages(language.German, &i18n.Message{ID: "invoice.title", Other: "Rechnung {{.Number}}"})
+
+	tr := templates.New()
+	tr.AddLocalizeFunc("T")
+	tr.AddLocalizer("de", i18n.NewLocalizer(bundle, "de"))
+	pdfgen.RegisterTemplateFuncs(tr)
+
+	fsys := fstest.MapFS{
+		"invoice.html": &fstest.MapFile{Data: []byte(`<h1>{{T "invoice.title" .}}</h1><img src="{{qrcode .URL}}">`)},
+	}
+
+	require.NoError(t, tr.Add(fsys, "*.html"))
+
+	var (
+		diagnostic = pdfgen.Diagnostic{Level: pdfgen.LevelWarning, Message: "fake"}
+		rendered   pdfgen.RenderOptions
+	)
+
+	r := pdfgen.RendererFunc(func(_ context.Context, w io.Writer, html io.Reader, opts pdfgen.RenderOptions) (*pdfgen.Result, error) {
+		rendered = opts
+
+		_, err := io.Copy(w, html)
+
+		return &pdfgen.Result{Diagnostics: []pdfgen.Diagnostic{diagnostic}}, err
+	})
+
+	data := map[string]string{"Number": "42", "URL": "https://example.com/pay"}
+	buff := bytes.NewBuffer(nil)
+
+	result, err := pdfgen.GenerateFromTemplateWithOptions(context.Background(), buff, r, tr, "invoice.html", data, "de",
+		pdfgen.RenderOptions{Page: pdfgen.PageSettings{Size: "A4"}})
+	require.NoError(t, err)
+	require.Equal(t, "A4", rendered.Page.Size)
+	require.Equal(t, []pdfgen.Diagnostic{diagnostic}, result.Warnings())
+	require.Contains(t, buff.String(), "<h1>Rechnung 42</h1>")
+	require.Contains(t, buff.String(), `<img src="data:image/png;base64,`)
+
+	_, err = pdfgen.GenerateFromTemplate(context.Background(), io.Discard, r, tr, "missing.html", data, "de")
+	require.ErrorIs(t, err, templates.ErrTemplateNotFound)
+}
@@ -1,6 +1,7 @@
 package templates
 
 import (
+	"errors"
 	"fmt"
 	"html/template"
 	"io"
@@ -8,6 +9,7 @@ import (
 	"net/http"
 	"path"
 	"strings"
+	"sync"
 
 	"github.com/labstack/echo/v4"
 	"github.com/nicksnyder/go-i18n/v2/i18n"
@@ -15,11 +17,38 @@ import (
 	"github.com/gosom/toolkit/pkg/errorsext"
 )
 
+var (
+	ErrTemplateNotFound  = errors.New("template not found")
+	ErrLocalizerNotFound = errors.New("localizer not found")
+)
+
 // Template stores the meta data for each template, and whether it uses a layout.
 type Template struct {
 	layout   string
 	name     string
 	template *template.Template
+
+	// base is never executed, so it can be cloned for each language.
+	base      *template.Template
+	mu        sync.Mutex
+	localized map[string]*template.Template
+}
+
+func newTemplate(layout, name string, tmpl *template.Template) (*Template, error) {
+	base, err := tmpl.Clone()
+	if err != nil {
+		return nil, errorsext.WithStack(err)
+	}
+
+	ans := Template{
+		layout:    layout,
+		name:      name,
+		template:  tmpl,
+		base:      base,
+		localized: make(map[string]*template.Template),
+	}
+
+	return &ans, nil
 }
 
 func (t *Template) Layout() string {
@@ -39,6 +68,9 @@ type TemplateRenderer struct {
 	templates     map[string]*Template
 	templateFuncs template.FuncMap
 	localizers    map[string]*i18n.Localizer
+	// localizeFunc is the name of the template function that translates
+	// messages, empty unless AddLocalizeFunc is called.
+	localizeFunc string
 }
 
 // New setup a new template renderer.
@@ -62,8 +94,29 @@ func (t *TemplateRenderer) String() string {
 	return strings.TrimSpace(sb.String())
 }
 
+// AddLocalizer registers the localizer used by Execute for lang. It
+// replaces the previous localizer of lang, also for templates already
+// executed with it.
 func (t *TemplateRenderer) AddLocalizer(lang string, localizer *i18n.Localizer) {
 	t.localizers[lang] = localizer
+
+	for _, tmpl := range t.templates {
+		tmpl.dropLocalized(lang)
+	}
+}
+
+// AddLocalizeFunc adds the template function name that translates messages
+// with the localizer of the language passed to Execute, e.g. with name T
+// {{T "invoice.title"}} or {{T "invoice.due" .}}. Without a language the
+// message id is returned. Like AddTemplateFunc it panics if name exists and
+// must be called before the templates are added.
+func (t *TemplateRenderer) AddLocalizeFunc(name string) {
+	t.AddTemplateFunc(name, localizeFunc(nil))
+	t.localizeFunc = name
+
+	for _, tmpl := range t.templates {
+		tmpl.dropLocalized()
+	}
 }
 
 func (t *TemplateRenderer) AddTemplateFunc(name string, fn any) {
@@ -90,10 +143,9 @@ func (t *TemplateRenderer) AddWithLayout(fsys fs.FS, layout string, patterns ...
 			return errorsext.WithStack(fmt.Errorf("%w: failed to parse template %s", err, f))
 		}
 
-		t.templates[tname] = &Template{
-			layout:   lname,
-			name:     tname,
-			template: tmp,
+		t.templates[tname], err = newTemplate(lname, tname, tmp)
+		if err != nil {
+			return err
 		}
 	}
 
@@ -116,10 +168,9 @@ func (t *TemplateRenderer) AddWithLayoutAndIncludes(fsys fs.FS, layout, includes
 			return errorsext.WithStack(fmt.Errorf("%w: failed to parse template %s", err, f))
 		}
 
-		t.templates[tname] = &Template{
-			layout:   lname,
-			name:     tname,
-			template: tmp,
+		t.templates[tname], err = newTemplate(lname, tname, tmp)
+		if err != nil {
+			return err
 		}
 	}
 
@@ -141,15 +192,48 @@ func (t *TemplateRenderer) Add(fsys fs.FS, patterns ...string) error {
 			return errorsext.WithStack(fmt.Errorf("%w: failed to parse template %s", err, f))
 		}
 
-		t.templates[tname] = &Template{
-			name:     tname,
-			template: tmp,
+		t.templates[tname], err = newTemplate("", tname, tmp)
+		if err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
+// Execute renders the template name to w without an echo.Context, e.g. for
+// emails and documents. If lang is not empty the function added with
+// AddLocalizeFunc translates messages using the localizer of lang.
+func (t *TemplateRenderer) Execute(w io.Writer, name string, data any, lang string) error {
+	tmpl, ok := t.templates[name]
+	if !ok {
+		return errorsext.WithStack(fmt.Errorf("%w: %s", ErrTemplateNotFound, name))
+	}
+
+	execName := tmpl.name
+	if tmpl.layout != "" {
+		execName = tmpl.layout
+	}
+
+	exec := tmpl.template
+
+	if lang != "" && t.localizeFunc != "" {
+		localizer, ok := t.localizers[lang]
+		if !ok {
+			return errorsext.WithStack(fmt.Errorf("%w: %s", ErrLocalizerNotFound, lang))
+		}
+
+		var err error
+
+		exec, err = tmpl.forLanguage(lang, t.localizeFunc, localizer)
+		if err != nil {
+			return err
+		}
+	}
+
+	return errorsext.WithStack(exec.ExecuteTemplate(w, execName, data))
+}
+
 // Render renders a template document.
 func (t *TemplateRenderer) Render(w io.Writer, name string, data interface{}, c echo.Context) error {
 	tmpl, ok := t.templates[name]
@@ -177,6 +261,67 @@ func (t *TemplateRenderer) GetTemplate(name string) (*Template, bool) {
 	return ans, ok
 }
 
+func (t *Template) forLanguage(lang, funcName string, localizer *i18n.Localizer) (*template.Template, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if ans, ok := t.localized[lang]; ok {
+		return ans, nil
+	}
+
+	ans, err := t.base.Clone()
+	if err != nil {
+		return nil, errorsext.WithStack(err)
+	}
+
+	ans.Funcs(template.FuncMap{funcName: localizeFunc(localizer)})
+
+	t.localized[lang] = ans
+
+	return ans, nil
+}
+
+// dropLocalized removes the templates cached by forLanguage for langs, or
+// for all languages without langs.
+func (t *Template) dropLocalized(langs ...string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(langs) == 0 {
+		clear(t.localized)
+
+		return
+	}
+
+	for _, lang := range langs {
+		delete(t.localized, lang)
+	}
+}
+
+// localizeFunc returns the template function translating with localizer.
+// The optional argument is the template data of the message. Messages that
+// cannot be localized are returned as their id.
+func localizeFunc(localizer *i18n.Localizer) func(id string, data ...any) string {
+	return func(id string, data ...any) string {
+		if localizer == nil {
+			return id
+		}
+
+		cfg := i18n.LocalizeConfig{MessageID: id}
+
+		if len(data) > 0 {
+			cfg.TemplateData = data[0]
+		}
+
+		ans, err := localizer.Localize(&cfg)
+		if err != nil {
+			return id
+		}
+
+		return ans
+	}
+}
+
 func readFileNames(fsys fs.FS, patterns ...string) ([]string, error) {
 	var filenames []string
 
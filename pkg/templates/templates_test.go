@@ -0,0 +1,75 @@
+package templates_test
+
+import (
+	"bytes"
+	"testing"
+	"testing/fstest"
+
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/language"
+
+	"github.com/gosom/toolkit/pkg/templates"
+)
+
+func Test_Execute(t *testing.T) {
+	t.Parallel()
+
+	bundle := i18n.NewBundle(language.English)
+	bundle.MustAddMessages(language.English, &i18n.Message{ID: "greeting", Other: "Hello {{.Name}}"})
+	bundle.MustAddMessages(language.German, &i18n.Message{ID: "greeting", Other: "Hallo {{.Name}}"})
+
+	tr := templates.New()
+	tr.AddLocalizeFunc("T")
+	tr.AddLocalizer("en", i18n.NewLocalizer(bundle, "en"))
+	tr.AddLocalizer("de", i18n.NewLocalizer(bundle, "de"))
+
+	fsys := fstest.MapFS{
+		"layout.html": &fstest.MapFile{Data: []byte(`{{define "layout.html"}}<p>{{template "content" .}}</p>{{end}}`)},
+		"page.html":   &fstest.MapFile{Data: []byte(`{{define "content"}}{{T "greeting" .}}{{end}}`)},
+	}
+
+	require.NoError(t, tr.AddWithLayout(fsys, "layout.html", "page.html"))
+
+	data := map[string]string{"Name": "Ana"}
+
+	for lang, expected := range map[string]string{"en": "<p>Hello Ana</p>", "de": "<p>Hallo Ana</p>", "": "<p>greeting</p>"} {
+		buff := bytes.NewBuffer(nil)
+
+		require.NoError(t, tr.Execute(buff, "page.html", data, lang))
+		require.Equal(t, expected, buff.String())
+	}
+
+	require.ErrorIs(t, tr.Execute(bytes.NewBuffer(nil), "page.html", data, "fr"), templates.ErrLocalizerNotFound)
+	require.ErrorIs(t, tr.Execute(bytes.NewBuffer(nil), "missing.html", data, "en"), templates.ErrTemplateNotFound)
+
+	// a localizer replaced after rendering is used from then on
+	tr.AddLocalizer("en", i18n.NewLocalizer(bundle, "de"))
+
+	buff := bytes.NewBuffer(nil)
+
+	require.NoError(t, tr.Execute(buff, "page.html", data, "en"))
+	require.Equal(t, "<p>Hallo Ana</p>", buff.String())
+}
+
+func Test_AddLocalizer_NoFunc(t *testing.T) {
+	t.Parallel()
+
+	bundle := i18n.NewBundle(language.English)
+
+	tr := templates.New()
+	tr.AddLocalizer("en", i18n.NewLocalizer(bundle, "en"))
+
+	// the localizer does not claim a function name
+	require.NotPanics(t, func() { tr.AddTemplateFunc("T", func() string { return "t" }) })
+	require.Panics(t, func() { tr.AddLocalizeFunc("T") })
+
+	fsys := fstest.MapFS{"page.html": &fstest.MapFile{Data: []byte(`{{T}}`)}}
+
+	require.NoError(t, tr.Add(fsys, "page.html"))
+
+	buff := bytes.NewBuffer(nil)
+
+	require.NoError(t, tr.Execute(buff, "page.html", nil, "en"))
+	require.Equal(t, "t", buff.String())
+}